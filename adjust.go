@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// adjustUnit is what + and - add or take away, times the count typed
+// before them
+const adjustUnit = time.Minute
+
+// arrowSteps are what the arrow keys add to a countdown, times the count
+// typed before them like + and -
+var arrowSteps = map[string]time.Duration{
+	"up":    time.Minute,
+	"down":  -time.Minute,
+	"right": 10 * time.Second,
+	"left":  -10 * time.Second,
+}
+
+// arrow adjusts a running countdown by the step of an arrow key. It
+// reports whether the key was one.
+func (t *timer) arrow(name string) bool {
+	step, ok := arrowSteps[name]
+	if !ok || t.mode == STOPWATCH || t.waiting || t.asking || t.prompt != "" {
+		return false
+	}
+	n := t.count
+	if n == 0 {
+		n = 1
+	}
+	t.count = 0
+	t.adjust(time.Duration(n) * step)
+	return true
+}
+
+// adjust adds d to the length of a countdown, or to the time on a
+// stopwatch. A countdown can't be made shorter than nothing, nor a
+// stopwatch go below zero.
+func (t *timer) adjust(d time.Duration) {
+	if t.mode == STOPWATCH {
+		if t.elapsed+d < 0 {
+			d = -t.elapsed
+		}
+		t.start = t.start.Add(-d)
+		t.elapsed += d
+	} else {
+		if t.duration+d < 0 {
+			d = -t.duration
+		}
+		t.duration += d
+		if t.elapsed <= t.duration {
+			t.overdue = false
+		}
+	}
+	t.rec.event("adjust", d.String())
+	t.draw()
+}
+
+// reset starts the countdown over with duration d, or a stopwatch over
+// from zero without its laps
+func (t *timer) reset(d time.Duration) {
+	t.duration = d
+	t.elapsed = 0
+	t.overdue = false
+	t.limited = false
+	if t.mode == STOPWATCH {
+		t.laps = nil
+		t.nextSplit = flags.autosplit
+	}
+	if !t.paused {
+		t.start = t.clock.Now()
+	}
+	t.rec.event("reset", d.String())
+	t.draw()
+}
+
+// maxCount is the largest count, almost a week of minutes
+const maxCount = 9999
+
+// countKey handles the keys that take a count: digits build up the count
+// and + or - use it, a count of minutes to add or take away, like 15+. It
+// reports whether the key was one of them.
+func (t *timer) countKey(char byte) bool {
+	n := t.count
+	if n == 0 {
+		n = 1
+	}
+	switch {
+	case char >= '0' && char <= '9':
+		// digits past the most a count can be are dropped
+		if c := t.count*10 + int(char-'0'); c <= maxCount {
+			t.count = c
+		}
+		t.draw()
+		return true
+	case char == '+':
+		t.count = 0
+		t.adjust(time.Duration(n) * adjustUnit)
+		return true
+	case char == '-':
+		t.count = 0
+		t.adjust(-time.Duration(n) * adjustUnit)
+		return true
+	}
+	if t.count != 0 {
+		t.count = 0
+		t.draw()
+	}
+	return false
+}
+
+// countLine shows a count that is being typed
+func (t *timer) countLine() string {
+	return fmt.Sprintf("%d (+ or - for minutes)", t.count)
+}
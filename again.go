@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// startArgs is the command line the timer was started with, after presets
+// and gutimer again are expanded, kept in the history and for gutimer
+// again. It is empty for timers that can't be started again from it, like
+// resumed ones.
+var startArgs []string
+
+// againState is what is kept of the last timer started for gutimer again
+type againState struct {
+	Args []string `json:"args"`
+}
+
+func againFile() string {
+	return filepath.Join(dataDir(), "again.json")
+}
+
+// keepAgain keeps the command line of a timer that is starting. Like the
+// results of gutimer last it is kept even with -history=false. The new one
+// is renamed over the old so a crash leaves one or the other.
+func keepAgain(args []string) error {
+	if err := os.MkdirAll(dataDir(), 0755); err != nil {
+		return err
+	}
+	b, err := json.Marshal(againState{Args: args})
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dataDir(), "again.*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(b, '\n'))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), againFile())
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// readAgain returns the command line kept by keepAgain, none if there is
+// none yet
+func readAgain() ([]string, error) {
+	b, err := os.ReadFile(againFile())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var state againState
+	if err := json.Unmarshal(b, &state); err != nil {
+		return nil, fmt.Errorf("%s: %v", againFile(), err)
+	}
+	return state.Args, nil
+}
+
+// againArgs is the command line of `gutimer again [flags]`, which starts
+// the last timer over with the command line it was started with. Without
+// one kept, it goes by the history, where timers from before gutimer again
+// only have their mode, duration and label. Flags given to it go in front
+// of the timer's own, which have the last word, as with a preset.
+func againArgs(extra []string) ([]string, error) {
+	args, err := readAgain()
+	if err != nil {
+		return nil, err
+	}
+	if args == nil {
+		entries, err := readHistory()
+		if err != nil {
+			return nil, fmt.Errorf("unable to read history: %v", err)
+		}
+		for i := len(entries) - 1; i >= 0 && args == nil; i-- {
+			if len(entries[i].Args) > 0 {
+				args = entries[i].Args
+			} else if a, ok := historyArgs(entries[i]); ok {
+				args = a
+			}
+		}
+	}
+	if args == nil {
+		return nil, fmt.Errorf("no timer to start again")
+	}
+	return withExtra(args, extra), nil
+}
+
+// withExtra puts the flags in extra in front of those in args, after the
+// subcommand if args starts with one
+func withExtra(args, extra []string) []string {
+	n := 0
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		n = 1
+	}
+	return append(append(append([]string(nil), args[:n]...), extra...), args[n:]...)
+}
+
+// showArgs writes a command line the way it would be typed
+func showArgs(args []string) string {
+	words := make([]string, len(args))
+	for i, a := range args {
+		words[i] = a
+		if a == "" || strings.ContainsAny(a, " \t'\"\\") {
+			words[i] = fmt.Sprintf("%q", a)
+		}
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// alarmLayouts are the accepted forms of an alarm time of day
+var alarmLayouts = []string{"15:04", "15:04:05"}
+
+// dateLayouts are the accepted forms of an alarm on a particular date
+var dateLayouts = []string{
+	"2006-01-02",
+	"2006-01-02 15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02T15:04:05",
+}
+
+// alarmSpec is a parsed alarm target: either a fixed moment, or a time of
+// day in loc that comes around every day
+type alarmSpec struct {
+	at    time.Time
+	daily *timeOfDay
+	loc   *time.Location
+}
+
+// parseAlarmSpec parses an alarm target. A target is an RFC 3339 timestamp,
+// or a date, a date and time, a time of day or a relative expression like
+// "tomorrow 9am", optionally followed by a time zone name like
+// America/New_York. Without a zone the local zone is used.
+func parseAlarmSpec(s string, now time.Time) (alarmSpec, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return alarmSpec{at: t, loc: t.Location()}, nil
+	}
+
+	spec := alarmSpec{loc: now.Location()}
+	rest := s
+	if i := strings.LastIndexByte(s, ' '); i >= 0 {
+		zone := s[i+1:]
+		loc, err := time.LoadLocation(zone)
+		if err == nil {
+			spec.loc = loc
+			rest = strings.TrimSpace(s[:i])
+		} else if strings.Contains(zone, "/") {
+			return spec, fmt.Errorf("unknown time zone %q", zone)
+		}
+	}
+
+	if tod, err := parseClock(rest); err == nil {
+		spec.daily = &tod
+		return spec, nil
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, rest); err == nil {
+			spec.at = wallClock(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), spec.loc)
+			return spec, nil
+		}
+	}
+	if at, ok := parseRelative(rest, now.In(spec.loc)); ok {
+		spec.at = at
+		return spec, nil
+	}
+	return spec, fmt.Errorf("invalid alarm time %q", s)
+}
+
+// clockWords are times of day that have names
+var clockWords = map[string]timeOfDay{
+	"noon":     {12, 0, 0},
+	"midday":   {12, 0, 0},
+	"midnight": {0, 0, 0},
+}
+
+// parseClock parses a time of day: 14:00, 14:00:30, 9am, 9:30pm or noon
+func parseClock(s string) (timeOfDay, error) {
+	s = strings.ToLower(strings.Replace(s, " ", "", -1))
+	if tod, ok := clockWords[s]; ok {
+		return tod, nil
+	}
+	if strings.HasSuffix(s, "am") || strings.HasSuffix(s, "pm") {
+		layout := "3"
+		if strings.Contains(s, ":") {
+			layout = "3:04"
+		}
+		if t, err := time.Parse(layout+"pm", s); err == nil {
+			return timeOfDay{t.Hour(), t.Minute(), 0}, nil
+		}
+		return timeOfDay{}, fmt.Errorf("invalid time of day %q", s)
+	}
+	return parseTimeOfDay(s)
+}
+
+var relativeUnits = map[string]time.Duration{
+	"second": time.Second,
+	"sec":    time.Second,
+	"minute": time.Minute,
+	"min":    time.Minute,
+	"hour":   time.Hour,
+	"hr":     time.Hour,
+	"day":    day,
+	"week":   week,
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+// parseRelative parses targets the way people say them:
+//
+//	in 20 minutes, in an hour, in 1h30m
+//	today 5pm, tomorrow 9am, tomorrow
+//	monday 10:00, next monday 14:00
+//
+// A weekday on its own is the next one that has not passed yet, possibly
+// today, while "next" always skips today. A day without a time means the
+// start of that day.
+func parseRelative(s string, now time.Time) (time.Time, bool) {
+	words := strings.Fields(strings.ToLower(s))
+	if len(words) == 0 {
+		return time.Time{}, false
+	}
+
+	if words[0] == "in" {
+		d, ok := parseSpokenDuration(words[1:])
+		return now.Add(d), ok
+	}
+
+	y, m, d := now.Date()
+	next := false
+	if words[0] == "next" {
+		next = true
+		words = words[1:]
+	}
+	if len(words) == 0 {
+		return time.Time{}, false
+	}
+	switch day := words[0]; {
+	case day == "today" && !next:
+	case day == "tomorrow" && !next:
+		d++
+	default:
+		wd, ok := weekdayNames[day]
+		if !ok {
+			wd, ok = weekdays[day]
+		}
+		if !ok {
+			return time.Time{}, false
+		}
+		ahead := (int(wd) - int(now.Weekday()) + 7) % 7
+		if next && ahead == 0 {
+			ahead = 7
+		}
+		d += ahead
+	}
+
+	var tod timeOfDay
+	if len(words) > 1 {
+		var err error
+		tod, err = parseClock(strings.Join(words[1:], ""))
+		if err != nil {
+			return time.Time{}, false
+		}
+	}
+	t := wallClock(y, m, d, tod.hour, tod.min, tod.sec, now.Location())
+	// "monday 9am" said on a Monday afternoon means next week
+	if !next && words[0] != "today" && words[0] != "tomorrow" && !t.After(now) {
+		t = wallClock(y, m, d+7, tod.hour, tod.min, tod.sec, now.Location())
+	}
+	return t, true
+}
+
+// parseSpokenDuration parses durations like "20 minutes", "an hour",
+// "1 hour 30 minutes" or anything parseDuration accepts
+func parseSpokenDuration(words []string) (time.Duration, bool) {
+	if len(words) == 0 {
+		return 0, false
+	}
+	if d, err := parseDuration(strings.Join(words, "")); err == nil {
+		return d, true
+	}
+	if len(words)%2 != 0 {
+		return 0, false
+	}
+	var total time.Duration
+	for i := 0; i < len(words); i += 2 {
+		n := words[i]
+		if n == "a" || n == "an" {
+			n = "1"
+		}
+		unit, ok := relativeUnits[strings.TrimSuffix(strings.TrimSuffix(words[i+1], "s"), ",")]
+		if !ok {
+			return 0, false
+		}
+		d, err := scaleNumber(n, unit)
+		if err != nil {
+			return 0, false
+		}
+		total += d
+	}
+	return total, true
+}
+
+// next returns the moment the alarm goes off, the first one after now for a
+// daily alarm
+func (a alarmSpec) next(now time.Time) time.Time {
+	if a.daily == nil {
+		return a.at
+	}
+	return nextWallClock(now, a.loc, a.daily.hour, a.daily.min, a.daily.sec)
+}
+
+// parseAlarm parses an alarm target and returns the moment it refers to
+func parseAlarm(s string, now time.Time) (time.Time, error) {
+	spec, err := parseAlarmSpec(s, now)
+	if err != nil {
+		return time.Time{}, err
+	}
+	target := spec.next(now)
+	if !target.After(now) {
+		return time.Time{}, fmt.Errorf("alarm time %q is in the past", s)
+	}
+	return target, nil
+}
+
+// nextWallClock returns the first moment after now where the clock in loc
+// reads hour:min:sec
+func nextWallClock(now time.Time, loc *time.Location, hour, min, sec int) time.Time {
+	local := now.In(loc)
+	y, m, d := local.Date()
+	target := wallClock(y, m, d, hour, min, sec, loc)
+	for !target.After(now) {
+		d++
+		target = wallClock(y, m, d, hour, min, sec, loc)
+	}
+	return target
+}
+
+// wallClock is time.Date, except that a wall clock time skipped by a
+// daylight saving transition is moved forward by the length of the gap
+// instead of being interpreted with the offset from before it, which would
+// land before the transition.
+func wallClock(y int, m time.Month, d, hour, min, sec int, loc *time.Location) time.Time {
+	t := time.Date(y, m, d, hour, min, sec, 0, loc)
+	want := time.Date(y, m, d, hour, min, sec, 0, time.UTC)
+	got := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+	return t.Add(want.Sub(got))
+}
+
+// a clock further off than this gets a warning when -ntp is used
+const ntpWarnOffset = time.Second
+
+// alarmDuration turns an alarm argument into the time left until it goes
+// off, checking the local clock first if -ntp was given
+func alarmDuration(arg string) (time.Duration, error) {
+	now := time.Now()
+	target, err := parseAlarm(arg, now)
+	if err != nil {
+		return 0, fmt.Errorf("Parse error: %v", err)
+	}
+	duration := target.Sub(now)
+	defer func() { flags.alarmAt = target }()
+
+	// far away targets are shown in days and whole seconds, which also means
+	// only redrawing once a second
+	if duration >= day {
+		flags.seconds = true
+	}
+
+	if flags.ntp != "" {
+		offset, err := ntpOffset(flags.ntp, 5*time.Second)
+		if err != nil {
+			return 0, fmt.Errorf("Unable to check clock against %s: %v", flags.ntp, err)
+		}
+		if flags.verbose {
+			fmt.Fprintf(os.Stderr, "Clock offset: %v\n", offset)
+		}
+		if offset > ntpWarnOffset || offset < -ntpWarnOffset {
+			if flags.ntpFix {
+				fmt.Fprintf(os.Stderr, "Local clock is off by %v, correcting alarm\n", offset)
+			} else {
+				fmt.Fprintf(os.Stderr, "Warning: local clock is off by %v according to %s\n", offset, flags.ntp)
+			}
+		}
+		if flags.ntpFix {
+			duration -= offset
+			target = target.Add(-offset)
+		}
+		if duration < 0 {
+			duration = 0
+		}
+	}
+	return duration, nil
+}
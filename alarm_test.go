@@ -0,0 +1,134 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func newYork(t *testing.T) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	return loc
+}
+
+// TestWallClock checks the times on either side of the daylight saving
+// changes of 2021 in New York: 2:00 to 2:59 never happened on March 14 and
+// 1:00 to 1:59 happened twice on November 7
+func TestWallClock(t *testing.T) {
+	loc := newYork(t)
+	for _, tt := range []struct {
+		d, hour, min int
+		m            time.Month
+		want         string
+	}{
+		{14, 1, 59, time.March, "2021-03-14T01:59:00-05:00"},
+		{14, 2, 0, time.March, "2021-03-14T03:00:00-04:00"},
+		{14, 2, 30, time.March, "2021-03-14T03:30:00-04:00"},
+		{14, 3, 0, time.March, "2021-03-14T03:00:00-04:00"},
+		{7, 0, 59, time.November, "2021-11-07T00:59:00-04:00"},
+		{7, 1, 30, time.November, "2021-11-07T01:30:00-04:00"},
+		{7, 2, 0, time.November, "2021-11-07T02:00:00-05:00"},
+	} {
+		got := wallClock(2021, tt.m, tt.d, tt.hour, tt.min, 0, loc)
+		if s := got.Format(time.RFC3339); s != tt.want {
+			t.Errorf("%v %d %02d:%02d: got %s, want %s", tt.m, tt.d, tt.hour, tt.min, s, tt.want)
+		}
+	}
+}
+
+// TestNextWallClock checks that a daily alarm rings at the same time on the
+// clock across a daylight saving change, which makes the day in between an
+// hour shorter or longer
+func TestNextWallClock(t *testing.T) {
+	loc := newYork(t)
+	for _, tt := range []struct {
+		now       string
+		hour, min int
+		want      string
+		wait      time.Duration
+	}{
+		// spring forward
+		{"2021-03-13T07:00:00-05:00", 7, 0, "2021-03-14T07:00:00-04:00", 23 * time.Hour},
+		{"2021-03-13T12:00:00-05:00", 2, 30, "2021-03-14T03:30:00-04:00", 14*time.Hour + 30*time.Minute},
+		{"2021-03-14T01:59:00-05:00", 3, 0, "2021-03-14T03:00:00-04:00", time.Minute},
+		// fall back
+		{"2021-11-06T07:00:00-04:00", 7, 0, "2021-11-07T07:00:00-05:00", 25 * time.Hour},
+		{"2021-11-07T00:30:00-04:00", 1, 30, "2021-11-07T01:30:00-04:00", time.Hour},
+		// once the first 1:30 has passed the next is tomorrow's, not the
+		// second one today
+		{"2021-11-07T01:45:00-04:00", 1, 30, "2021-11-08T01:30:00-05:00", 24*time.Hour + 45*time.Minute},
+	} {
+		now, err := time.Parse(time.RFC3339, tt.now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := nextWallClock(now, loc, tt.hour, tt.min, 0)
+		if s := got.Format(time.RFC3339); s != tt.want {
+			t.Errorf("after %s, %02d:%02d: got %s, want %s", tt.now, tt.hour, tt.min, s, tt.want)
+		}
+		if wait := got.Sub(now); wait != tt.wait {
+			t.Errorf("after %s, %02d:%02d: waits %v, want %v", tt.now, tt.hour, tt.min, wait, tt.wait)
+		}
+	}
+}
+
+// stepWall has deadlineClock read a wall clock that only moves when the
+// test steps it, like NTP does
+func stepWall(t *testing.T) func(d time.Duration) {
+	t.Helper()
+	now := time.Now().Round(0)
+	saved := wallNow
+	wallNow = func() time.Time { return now }
+	t.Cleanup(func() { wallNow = saved })
+	return func(d time.Duration) { now = now.Add(d) }
+}
+
+// TestClockStep checks that stepping the wall clock moves an alarm with it,
+// for better or worse, and leaves a countdown alone
+func TestClockStep(t *testing.T) {
+	step := stepWall(t)
+	saved := flags
+	t.Cleanup(func() { flags = saved })
+	flags = Flags{}
+	for _, tt := range []struct {
+		mode Mode
+		step time.Duration
+		want time.Duration
+	}{
+		{ALARM, 10 * time.Minute, 10 * time.Minute},
+		{ALARM, time.Hour, 30 * time.Minute},
+		{ALARM, -time.Hour, -time.Hour},
+		{COUNTDOWN, time.Hour, 0},
+		{COUNTDOWN, -time.Hour, 0},
+	} {
+		tm := newTimer(realClock{}, tt.mode, 30*time.Minute)
+		tm.start = tm.clock.Now()
+		step(tt.step)
+		got := tm.status().Elapsed
+		if tt.mode == COUNTDOWN {
+			// only real time passes
+			got = got.Truncate(time.Minute)
+		}
+		if got != tt.want {
+			t.Errorf("%v after a step of %v: elapsed %v, want %v", tt.mode, tt.step, got, tt.want)
+		}
+	}
+}
+
+// TestDeadlineClock checks that a -hard countdown is timed by the wall
+// clock, and that its readings have no monotonic part to fall back on
+func TestDeadlineClock(t *testing.T) {
+	step := stepWall(t)
+	var c Clock = deadlineClock{}
+	start := c.Now()
+	if start != start.Round(0) {
+		t.Fatal("deadlineClock reading has a monotonic part")
+	}
+	step(90 * time.Second)
+	if got := c.Now().Sub(start); got != 90*time.Second {
+		t.Errorf("after a step of 90s: %v", got)
+	}
+}
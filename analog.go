@@ -0,0 +1,68 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// analogRadius is the radius of the -analog clock face in lines. Cells are
+// about twice as tall as they are wide, so the face is twice as many
+// columns across.
+const analogRadius = 5
+
+// analogFace draws a clock face with a hand at fraction of a full turn,
+// clockwise from 12. The part of the face the hand has swept is shaded.
+func analogFace(fraction float64) []string {
+	const r = analogRadius
+	grid := make([][]rune, 2*r+1)
+	for y := range grid {
+		grid[y] = make([]rune, 4*r+1)
+		for x := range grid[y] {
+			dx, dy := float64(x-2*r)/2, float64(y-r)
+			dist := math.Hypot(dx, dy)
+			// angle clockwise from 12 in turns
+			angle := math.Atan2(dx, -dy) / (2 * math.Pi)
+			if angle < 0 {
+				angle++
+			}
+			switch {
+			case math.Abs(dist-r) < 0.5:
+				grid[y][x] = '·'
+			case dist < r && angle < fraction:
+				grid[y][x] = '░'
+			default:
+				grid[y][x] = ' '
+			}
+		}
+	}
+	grid[0][2*r-1], grid[0][2*r] = '1', '2'
+	grid[r][4*r] = '3'
+	grid[2*r][2*r] = '6'
+	grid[r][0] = '9'
+
+	sin, cos := math.Sincos(2 * math.Pi * fraction)
+	for t := 0.5; t < r-0.5; t += 0.25 {
+		x := 2*r + int(math.Round(2*t*sin))
+		y := r - int(math.Round(t*cos))
+		grid[y][x] = '█'
+	}
+	grid[r][2*r] = '●'
+
+	lines := make([]string, len(grid))
+	for i, row := range grid {
+		lines[i] = string(row)
+	}
+	return lines
+}
+
+// analogFraction is where the hand points: how much of a countdown is done,
+// or the second hand of a stopwatch
+func analogFraction(mode Mode, total time.Duration, duration time.Duration) float64 {
+	if mode == STOPWATCH {
+		return float64(duration%time.Minute) / float64(time.Minute)
+	}
+	if total <= 0 || duration >= total {
+		return 1
+	}
+	return float64(duration) / float64(total)
+}
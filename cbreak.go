@@ -0,0 +1,29 @@
+package main
+
+import "golang.org/x/sys/unix"
+
+// cbreak turns off line editing and echo on the terminal fd
+func cbreak(fd int) error {
+	termios, err := unix.IoctlGetTermios(fd, ioctlGetTermios)
+	if err != nil {
+		return err
+	}
+	termios.Lflag &^= unix.ICANON | unix.ECHO
+	termios.Cc[unix.VMIN] = 1
+	termios.Cc[unix.VTIME] = 0
+	return unix.IoctlSetTermios(fd, ioctlSetTermios, termios)
+}
+
+// keysOnly is cbreak with the keys that send signals, like C-c, read as
+// keys too
+func keysOnly(fd int) error {
+	if err := cbreak(fd); err != nil {
+		return err
+	}
+	termios, err := unix.IoctlGetTermios(fd, ioctlGetTermios)
+	if err != nil {
+		return err
+	}
+	termios.Lflag &^= unix.ISIG
+	return unix.IoctlSetTermios(fd, ioctlSetTermios, termios)
+}
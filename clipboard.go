@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/base64"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// copy puts s on the clipboard of the terminal with OSC 52, which also works
+// over ssh where the terminal supports it
+func (l *layout) copy(s string) {
+	io.WriteString(l.w, "\x1b]52;c;"+base64.StdEncoding.EncodeToString([]byte(s))+"\a")
+}
+
+// clipboardCommand returns a command that puts what it reads on the local
+// clipboard, nil if there is no local desktop or no tool for it
+func clipboardCommand() *exec.Cmd {
+	if os.Getenv("SSH_TTY") != "" {
+		return nil
+	}
+	switch {
+	case os.Getenv("WAYLAND_DISPLAY") != "":
+		if path, err := exec.LookPath("wl-copy"); err == nil {
+			return exec.Command(path)
+		}
+	case os.Getenv("DISPLAY") != "":
+		if path, err := exec.LookPath("xclip"); err == nil {
+			return exec.Command(path, "-selection", "clipboard")
+		}
+	}
+	if path, err := exec.LookPath("pbcopy"); err == nil {
+		return exec.Command(path)
+	}
+	return nil
+}
+
+// yank copies the time on the display, what is left of a countdown or the
+// time on a stopwatch, without the brackets around it
+func (t *timer) yank() {
+	d := t.elapsed
+	if t.mode != STOPWATCH {
+		d = t.duration - t.elapsed
+	}
+	s := strings.Trim(printDuration(d), "[]")
+	screen.copy(s)
+	if cmd := clipboardCommand(); cmd != nil {
+		cmd.Stdin = strings.NewReader(s)
+		start(cmd)
+	}
+	t.rec.event("copy", s)
+	screen.print("Copied " + s + "\n")
+	t.draw()
+}
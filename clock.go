@@ -0,0 +1,72 @@
+package main
+
+import "time"
+
+// Clock is the source of time for the run loop. Everything that needs the
+// current time or a periodic wakeup goes through a Clock so the loop can be
+// driven by something other than the wall clock.
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker is the subset of time.Ticker used by the run loop
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+type realTicker struct {
+	*time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
+
+// deadlineClock is the wall clock without the monotonic reading, so time
+// spent suspended counts toward a -hard deadline or an alarm like any other
+// time, and setting the clock moves an alarm with it
+type deadlineClock struct {
+	realClock
+}
+
+// wallNow reads the wall clock for deadlineClock, and can be swapped for
+// one a test steps
+var wallNow = time.Now
+
+func (deadlineClock) Now() time.Time {
+	return wallNow().Round(0)
+}
+
+// scaledClock runs factor times faster than base, starting from the moment it
+// was created. Tickers are not scaled so the display refreshes at the same
+// rate no matter how fast simulated time passes.
+type scaledClock struct {
+	base   Clock
+	origin time.Time
+	factor float64
+}
+
+func newScaledClock(base Clock, factor float64) *scaledClock {
+	return &scaledClock{base: base, origin: base.Now(), factor: factor}
+}
+
+func (s *scaledClock) Now() time.Time {
+	elapsed := s.base.Now().Sub(s.origin)
+	return s.origin.Add(time.Duration(float64(elapsed) * s.factor))
+}
+
+func (s *scaledClock) NewTicker(d time.Duration) Ticker {
+	return s.base.NewTicker(d)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// startTest starts a simulation of args for a test, to be stopped at its
+// end
+func startTest(t *testing.T, args string) *simulation {
+	t.Helper()
+	sim, err := startSimulation(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(sim.stop)
+	return sim
+}
+
+func (s *simulation) elapsed(t *testing.T) time.Duration {
+	t.Helper()
+	st, ok := s.t.query(s.ctx)
+	if !ok {
+		t.Fatal("run is over")
+	}
+	return st.Elapsed
+}
+
+func (s *simulation) over() bool {
+	select {
+	case <-s.ended:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestCountdownEnds(t *testing.T) {
+	sim := startTest(t, "-c 10s")
+	sim.advance(10 * time.Second)
+	if sim.over() {
+		t.Fatal("countdown ended at 10s, before going past its end")
+	}
+	sim.advance(time.Second)
+	if !sim.over() {
+		t.Fatal("countdown still running at 11s")
+	}
+	if sim.code != 0 || !sim.t.completed {
+		t.Errorf("countdown exited with %d, completed %v", sim.code, sim.t.completed)
+	}
+	if got := strings.Join(screen.last, "\n"); !strings.Contains(got, "[00:00:00.00]") {
+		t.Errorf("last frame %q does not show the end", got)
+	}
+}
+
+func TestPauseResume(t *testing.T) {
+	sim := startTest(t, "-s")
+	sim.advance(2 * time.Second)
+	sim.keys(" ")
+	sim.advance(5 * time.Second)
+	if got := sim.elapsed(t); got != 2*time.Second {
+		t.Errorf("paused at %v, want 2s", got)
+	}
+	sim.keys(" ")
+	sim.advance(time.Second)
+	if got := sim.elapsed(t); got != 3*time.Second {
+		t.Errorf("resumed to %v, want 3s", got)
+	}
+}
+
+// TestTickDisplay checks that each tick draws the time the clock says,
+// truncated to what the display shows
+func TestTickDisplay(t *testing.T) {
+	for _, tt := range []struct {
+		args    string
+		advance time.Duration
+		want    string
+	}{
+		{"-c 10s", 2500 * time.Millisecond, "[00:00:07.50]"},
+		{"-c 10s", 2505 * time.Millisecond, "[00:00:07.50]"},
+		{"-s", 61 * time.Second, "[00:01:01.00]"},
+		{"-s", 1234 * time.Millisecond, "[00:00:01.23]"},
+		// nothing is drawn in between ticks
+		{"-refresh 1s -s", 1900 * time.Millisecond, "[00:00:01.00]"},
+		{"-refresh 1s -c 1m", 2500 * time.Millisecond, "[00:00:58.00]"},
+	} {
+		sim := startTest(t, tt.args)
+		sim.advance(tt.advance)
+		if got := strings.Join(screen.last, "\n"); !strings.Contains(got, tt.want) {
+			t.Errorf("%s after %v: got %q, want %s", tt.args, tt.advance, got, tt.want)
+		}
+		sim.stop()
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// colorDepth is how many colors the terminal can show
+type colorDepth int
+
+const (
+	noColor colorDepth = iota
+	color16
+	color256
+	trueColor
+)
+
+var depthNames = map[string]colorDepth{
+	"never":     noColor,
+	"16":        color16,
+	"256":       color256,
+	"truecolor": trueColor,
+}
+
+// depth is the color depth the display uses, set by -color
+var depth = color16
+
+// parseColorDepth parses -color: auto to detect it, or a depth from
+// depthNames
+func parseColorDepth(s string) (colorDepth, error) {
+	if s == "auto" {
+		return detectColors(), nil
+	}
+	d, ok := depthNames[s]
+	if !ok {
+		return noColor, fmt.Errorf("Unknown color depth %q", s)
+	}
+	return d, nil
+}
+
+// detectColors works out the color depth from the environment the way most
+// terminal programs do: NO_COLOR turns colors off, COLORTERM announces
+// truecolor, and otherwise the terminfo entry for TERM says how many colors
+// there are.
+func detectColors() colorDepth {
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return noColor
+	}
+	switch strings.ToLower(os.Getenv("COLORTERM")) {
+	case "truecolor", "24bit":
+		return trueColor
+	}
+	term := os.Getenv("TERM")
+	if term == "" || term == "dumb" {
+		return noColor
+	}
+	n := terminfoColors(term)
+	if n < 0 && strings.Contains(term, "256color") {
+		n = 256
+	}
+	switch {
+	case n >= 1<<24:
+		return trueColor
+	case n >= 256:
+		return color256
+	case n == 0:
+		return noColor
+	}
+	return color16
+}
+
+// terminfoColors reads the number of colors from the compiled terminfo entry
+// for term, or returns -1 if there is none
+func terminfoColors(term string) int {
+	var dirs []string
+	if d := os.Getenv("TERMINFO"); d != "" {
+		dirs = append(dirs, d)
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, ".terminfo"))
+	}
+	dirs = append(dirs, filepath.SplitList(os.Getenv("TERMINFO_DIRS"))...)
+	dirs = append(dirs, "/etc/terminfo", "/lib/terminfo", "/usr/share/terminfo")
+
+	for _, dir := range dirs {
+		// entries are filed under their first letter, or its hex code on macOS
+		for _, sub := range []string{term[:1], fmt.Sprintf("%x", term[0])} {
+			b, err := ioutil.ReadFile(filepath.Join(dir, sub, term))
+			if err == nil {
+				return parseTerminfoColors(b)
+			}
+		}
+	}
+	return -1
+}
+
+// the index of max_colors among the numeric capabilities
+const terminfoMaxColors = 13
+
+// parseTerminfoColors pulls max_colors out of a compiled terminfo entry,
+// either the legacy format with 16 bit numbers or the extended one with 32
+// bit numbers
+func parseTerminfoColors(b []byte) int {
+	if len(b) < 12 {
+		return -1
+	}
+	var header [6]int
+	for i := range header {
+		header[i] = int(int16(binary.LittleEndian.Uint16(b[2*i:])))
+	}
+	size := 2
+	switch header[0] {
+	case 0432:
+	case 01036:
+		size = 4
+	default:
+		return -1
+	}
+	names, bools, numbers := header[1], header[2], header[3]
+	if numbers <= terminfoMaxColors {
+		return -1
+	}
+	off := 12 + names + bools
+	if off%2 != 0 {
+		off++
+	}
+	off += terminfoMaxColors * size
+	if off+size > len(b) {
+		return -1
+	}
+	if size == 2 {
+		return int(int16(binary.LittleEndian.Uint16(b[off:])))
+	}
+	return int(int32(binary.LittleEndian.Uint32(b[off:])))
+}
+
+// rgb returns the SGR foreground color closest to r, g, b that depth can
+// show
+func rgb(r, g, b int) string {
+	switch depth {
+	case noColor:
+		return ""
+	case trueColor:
+		return fmt.Sprintf("38;2;%d;%d;%d", r, g, b)
+	case color256:
+		cube := func(c int) int { return (c*5 + 127) / 255 }
+		return "38;5;" + strconv.Itoa(16+36*cube(r)+6*cube(g)+cube(b))
+	}
+	bit := func(c int, v int) int {
+		if c > 127 {
+			return v
+		}
+		return 0
+	}
+	return strconv.Itoa(30 + bit(r, 1) + bit(g, 2) + bit(b, 4))
+}
+
+// parseHexColor parses a #rrggbb color into the SGR color for depth
+func parseHexColor(s string) (string, error) {
+	if len(s) != 7 || s[0] != '#' {
+		return "", fmt.Errorf("invalid color %q", s)
+	}
+	n, err := strconv.ParseUint(s[1:], 16, 32)
+	if err != nil {
+		return "", fmt.Errorf("invalid color %q", s)
+	}
+	return rgb(int(n>>16), int(n>>8&0xff), int(n&0xff)), nil
+}
+
+// gradient is a color going from green through yellow to red as f goes from
+// 0 to 1
+func gradient(f float64) string {
+	if depth == color16 {
+		switch {
+		case f < 0.5:
+			return rgb(0, 255, 0)
+		case f < 0.8:
+			return rgb(255, 255, 0)
+		}
+		return rgb(255, 0, 0)
+	}
+	r, g := 2*f, 2*(1-f)
+	if r > 1 {
+		r = 1
+	}
+	if g > 1 {
+		g = 1
+	}
+	return rgb(int(220*r), int(200*g), 0)
+}
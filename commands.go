@@ -0,0 +1,199 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// a command changes a running timer. It reports whether the timer should
+// stop.
+type command struct {
+	usage string
+	run   func(t *timer, args []string) (bool, error)
+}
+
+// commands are what can be typed at the : prompt
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"add": {"add duration", func(t *timer, args []string) (bool, error) {
+			d, err := commandDuration(args)
+			if err == nil {
+				t.adjust(d)
+			}
+			return false, err
+		}},
+		"sub": {"sub duration", func(t *timer, args []string) (bool, error) {
+			d, err := commandDuration(args)
+			if err == nil {
+				t.adjust(-d)
+			}
+			return false, err
+		}},
+		"reset": {"reset [duration]", func(t *timer, args []string) (bool, error) {
+			d := t.duration
+			if t.mode == STOPWATCH {
+				if len(args) != 0 {
+					return false, fmt.Errorf("a stopwatch resets to zero")
+				}
+			} else if len(args) != 0 {
+				var err error
+				if d, err = commandDuration(args); err != nil {
+					return false, err
+				}
+			}
+			t.reset(d)
+			return false, nil
+		}},
+		"lap": {"lap", func(t *timer, args []string) (bool, error) {
+			if t.mode != STOPWATCH {
+				return false, fmt.Errorf("only a stopwatch takes laps")
+			}
+			t.lapNow()
+			return false, nil
+		}},
+		"note": {"note text", func(t *timer, args []string) (bool, error) {
+			return false, t.note(strings.Join(args, " "))
+		}},
+		"unlap": {"unlap", func(t *timer, args []string) (bool, error) {
+			return false, t.unlap()
+		}},
+		"copy": {"copy", func(t *timer, args []string) (bool, error) {
+			t.yank()
+			return false, nil
+		}},
+		"label": {"label [text]", func(t *timer, args []string) (bool, error) {
+			flags.label = strings.Join(args, " ")
+			return false, nil
+		}},
+		"set": {"set flag value", func(t *timer, args []string) (bool, error) {
+			if len(args) != 2 {
+				return false, fmt.Errorf("usage: set flag value")
+			}
+			return false, setFlag(args[0], args[1])
+		}},
+		"pause": {"pause", func(t *timer, args []string) (bool, error) {
+			t.pause()
+			return false, nil
+		}},
+		"resume": {"resume", func(t *timer, args []string) (bool, error) {
+			t.resume()
+			return false, nil
+		}},
+		"toggle": {"toggle", func(t *timer, args []string) (bool, error) {
+			if t.paused {
+				t.resume()
+			} else {
+				t.pause()
+			}
+			return false, nil
+		}},
+		"detach": {"detach", func(t *timer, args []string) (bool, error) {
+			err := t.handoff()
+			return err == nil, err
+		}},
+		"quit": {"quit", func(t *timer, args []string) (bool, error) {
+			return true, nil
+		}},
+		"help": {"help", func(t *timer, args []string) (bool, error) {
+			var names []string
+			for _, c := range commands {
+				names = append(names, c.usage)
+			}
+			sort.Strings(names)
+			screen.print("Commands: " + strings.Join(names, ", ") + "\n")
+			return false, nil
+		}},
+	}
+}
+
+// exec runs a command line like "add 10m" against the timer. A leading :
+// is allowed, as typed at the prompt.
+func (t *timer) exec(line string) (bool, error) {
+	words := strings.Fields(strings.TrimPrefix(strings.TrimSpace(line), ":"))
+	if len(words) == 0 {
+		return false, nil
+	}
+	c, ok := commands[words[0]]
+	if !ok {
+		return false, fmt.Errorf("unknown command %q, try help", words[0])
+	}
+	t.rec.event("command", strings.Join(words, " "))
+	quit, err := c.run(t, words[1:])
+	t.draw()
+	return quit, err
+}
+
+func commandDuration(args []string) (time.Duration, error) {
+	if len(args) == 0 {
+		return 0, fmt.Errorf("missing duration")
+	}
+	return parseDuration(strings.Join(args, ""))
+}
+
+// displaySet is the flag set the display flags were registered on, which
+// the set command changes
+var displaySet *flag.FlagSet
+
+// setFlag changes a display flag of the running timer
+func setFlag(name, value string) error {
+	if displaySet == nil || displaySet.Lookup(name) == nil || !displayFlagNames[name] {
+		return fmt.Errorf("unknown display flag %q", name)
+	}
+	if err := displaySet.Set(name, value); err != nil {
+		return err
+	}
+	return checkDisplayFlags()
+}
+
+// the keys that edit a prompt
+const (
+	enter     = '\r'
+	newline   = '\n'
+	escape    = '\x1b'
+	backspace = '\x7f'
+	ctrlH     = '\x08'
+)
+
+// ask opens a one line prompt under the timer. What is typed goes to
+// submit on enter, which reports whether to quit.
+func (t *timer) ask(prompt string, submit func(string) (bool, error)) {
+	t.prompt = prompt
+	t.submit = submit
+	t.input = ""
+	t.draw()
+}
+
+// promptKey edits the line typed at a prompt and submits it on enter. It
+// reports whether what was submitted asked to quit.
+func (t *timer) promptKey(char byte) bool {
+	switch char {
+	case enter, newline:
+		line, submit := t.input, t.submit
+		t.prompt, t.submit, t.input = "", nil, ""
+		quit, err := submit(line)
+		if err != nil {
+			screen.print(fmt.Sprintf("%v\n", err))
+		}
+		t.draw()
+		return quit
+	case escape:
+		t.prompt, t.submit, t.input = "", nil, ""
+	case backspace, ctrlH:
+		// a whole character, however many bytes it took
+		_, n := utf8.DecodeLastRuneInString(t.input)
+		t.input = t.input[:len(t.input)-n]
+	default:
+		if char >= ' ' {
+			// the byte as it is, as one of a UTF-8 sequence could be
+			t.input += string([]byte{char})
+		}
+	}
+	t.draw()
+	return false
+}
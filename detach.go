@@ -0,0 +1,245 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// A timer can be handed over to another copy of gutimer that carries on
+// from where it got to. -detach starts one in the background, with no
+// terminal, as with -batch; C-\ or :detach sends a running one there; and
+// gutimer attach brings the last one detached back into the foreground.
+// The time so far goes along in GUTIMER_OFFSET rather than as -offset, so
+// the command line kept for gutimer again stays the one typed.
+
+// detachedEnv marks the copy running in the background, which hands the
+// timer back to gutimer attach instead of detaching again
+const detachedEnv = "GUTIMER_DETACHED"
+
+// detachedTimer is the timer detached last, for gutimer attach to find
+type detachedTimer struct {
+	Pid    int      `json:"pid,omitempty"` // none once it has handed itself back
+	Args   []string `json:"args"`
+	Offset string   `json:"offset,omitempty"` // how far it had got
+	Paused bool     `json:"paused,omitempty"`
+}
+
+// handoff is what runMode detaches once everything else is cleaned up, so a
+// terminal or an address of the timer is let go of first
+var handoff *detachedTimer
+
+// terminalFlags are the flags a timer in the background can't take, and
+// one that has started already doesn't need
+var terminalFlags = map[string]bool{
+	"detach": true, "wait": true, "start-paused": true, "mouse": true, "focus-pause": true,
+}
+
+func detachedFile() string {
+	return filepath.Join(dataDir(), "detached.json")
+}
+
+// handoffArgs are args without the -offset and terminalFlags, which the
+// copy carrying on from a timer gets some other way or can't take
+func handoffArgs(args []string) []string {
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" || !strings.HasPrefix(arg, "-") {
+			return append(rest, args[i:]...)
+		}
+		name := strings.TrimLeft(arg, "-")
+		if j := strings.IndexByte(name, '='); j >= 0 {
+			name = name[:j]
+		} else if name == "offset" {
+			i++
+			continue
+		}
+		if name != "offset" && !terminalFlags[name] {
+			rest = append(rest, arg)
+		}
+	}
+	return rest
+}
+
+// withEnv is env with the variables in set, and without any of the names
+// in unset or set that it had already
+func withEnv(env []string, unset []string, set ...string) []string {
+	drop := map[string]bool{}
+	for _, kv := range append(unset, set...) {
+		drop[strings.SplitN(kv, "=", 2)[0]] = true
+	}
+	var out []string
+	for _, kv := range env {
+		if !drop[strings.SplitN(kv, "=", 2)[0]] {
+			out = append(out, kv)
+		}
+	}
+	return append(out, set...)
+}
+
+// handoffEnv are the variables that carry a handed over timer
+var handoffEnv = []string{detachedEnv, envName("batch"), envName("offset"), envName("start-paused")}
+
+// handoff hands t over to another copy of gutimer: one in the background,
+// started once this one has cleaned up, or the one gutimer attach starts
+// in the foreground once this one in the background is gone. t then ends
+// without going in the history, which the copy that ends it writes.
+func (t *timer) handoff() error {
+	switch {
+	case t.phased || flags.every != nil || flags.command != nil || flags.name != "",
+		t.mode != COUNTDOWN && t.mode != TIMER && t.mode != STOPWATCH && t.mode != ALARM:
+		return errors.New("only a single timer can be detached")
+	case len(startArgs) == 0 || subcommands[startArgs[0]]:
+		return errors.New("only a timer started from its flags can be detached")
+	case t.waiting:
+		return errors.New("a timer yet to start can't be detached")
+	}
+	d := &detachedTimer{Args: handoffArgs(startArgs), Paused: t.paused}
+	if t.mode != ALARM {
+		elapsed := t.elapsed
+		if !t.paused {
+			elapsed = t.clock.Now().Sub(t.start)
+		}
+		d.Offset = elapsed.String()
+	}
+	if os.Getenv(detachedEnv) != "" {
+		// gutimer attach is waiting for this
+		if err := keepDetached(d); err != nil {
+			return err
+		}
+	} else {
+		if t.paused {
+			return errors.New("a paused timer can't be detached")
+		}
+		handoff = d
+	}
+	t.detached = true
+	return nil
+}
+
+// keepDetached keeps d for gutimer attach, renamed over the last one
+func keepDetached(d *detachedTimer) error {
+	if err := os.MkdirAll(dataDir(), 0755); err != nil {
+		return err
+	}
+	b, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	tmp := detachedFile() + ".tmp"
+	if err := os.WriteFile(tmp, append(b, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, detachedFile())
+}
+
+func readDetached() (*detachedTimer, error) {
+	b, err := os.ReadFile(detachedFile())
+	if err != nil {
+		return nil, err
+	}
+	var d detachedTimer
+	if err := json.Unmarshal(b, &d); err != nil {
+		return nil, fmt.Errorf("%s: %v", detachedFile(), err)
+	}
+	return &d, nil
+}
+
+// detach starts the timer of d in the background, in a session of its own
+// with no terminal, and returns as soon as it is running. It runs as under
+// -batch; gutimer attach, -serve or -export are how to keep up with it.
+func detach(d *detachedTimer) int {
+	exe, err := os.Executable()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to detach: %v\n", err)
+		return 1
+	}
+	null, err := os.OpenFile(os.DevNull, os.O_RDWR, 0)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to detach: %v\n", err)
+		return 1
+	}
+	defer null.Close()
+
+	cmd := exec.Command(exe, d.Args...)
+	set := []string{detachedEnv + "=1", envName("batch") + "=true"}
+	if d.Offset != "" {
+		set = append(set, envName("offset")+"="+d.Offset)
+	}
+	cmd.Env = withEnv(os.Environ(), handoffEnv, set...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = null, null, null
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to detach: %v\n", err)
+		return 1
+	}
+	d.Pid = cmd.Process.Pid
+	if err := keepDetached(d); err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to keep the detached timer for gutimer attach: %v\n", err)
+	}
+	fmt.Fprintf(os.Stderr, "Running in the background as process %d, gutimer attach brings it back\n", d.Pid)
+	cmd.Process.Release()
+	return 0
+}
+
+// attachWait is how long gutimer attach waits for the timer in the
+// background to hand itself back
+const attachWait = 5 * time.Second
+
+// attach handles `gutimer attach`, which brings the timer detached last
+// back into the foreground. It sends the one in the background SIGQUIT,
+// which hands it over, and then becomes a copy that carries on with it.
+func attach(args []string) int {
+	if len(args) != 0 {
+		fmt.Fprintln(os.Stderr, "Usage: gutimer attach")
+		return 1
+	}
+	d, err := readDetached()
+	if os.IsNotExist(err) || err == nil && (d.Pid == 0 || !processAlive(d.Pid)) {
+		fmt.Fprintln(os.Stderr, "No detached timer is running")
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to find the detached timer: %v\n", err)
+		return 1
+	}
+	pid := d.Pid
+	if err := syscall.Kill(pid, syscall.SIGQUIT); err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to attach to process %d: %v\n", pid, err)
+		return 1
+	}
+	for deadline := time.Now().Add(attachWait); processAlive(pid); time.Sleep(50 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			fmt.Fprintf(os.Stderr, "Process %d did not hand its timer over\n", pid)
+			return 1
+		}
+	}
+	if d, err = readDetached(); err != nil || d.Pid != 0 {
+		fmt.Fprintf(os.Stderr, "Process %d ended without handing its timer over\n", pid)
+		return 1
+	}
+	os.Remove(detachedFile())
+
+	exe, err := os.Executable()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to attach: %v\n", err)
+		return 1
+	}
+	var set []string
+	if d.Offset != "" {
+		set = append(set, envName("offset")+"="+d.Offset)
+	}
+	if d.Paused {
+		set = append(set, envName("start-paused")+"=true")
+	}
+	err = syscall.Exec(exe, append([]string{os.Args[0]}, d.Args...), withEnv(os.Environ(), handoffEnv, set...))
+	fmt.Fprintf(os.Stderr, "Unable to attach: %v\n", err)
+	return 1
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// resolution is the smallest unit shown on the display
+func resolution() time.Duration {
+	switch {
+	case flags.seconds, flags.human:
+		return time.Second
+	case flags.hires:
+		return time.Millisecond
+	}
+	return 10 * time.Millisecond
+}
+
+// refreshInterval is how often the display is redrawn: once per displayed
+// unit unless -refresh says otherwise, but no more than once a second on
+// battery or while the terminal is not keeping up
+func refreshInterval() time.Duration {
+	if flags.refresh > 0 {
+		return flags.refresh
+	}
+	if (onBattery || screen.slow()) && resolution() < batteryRefresh {
+		return batteryRefresh
+	}
+	return resolution()
+}
+
+// roundDisplay rounds d to a multiple of unit using the -round method
+func roundDisplay(d time.Duration, unit time.Duration) time.Duration {
+	switch flags.round {
+	case "round":
+		return d.Round(unit)
+	case "ceil":
+		if r := d.Truncate(unit); r != d {
+			return r + unit
+		}
+	}
+	return d.Truncate(unit)
+}
+
+// printDuration formats a duration for the display. Negative durations get
+// a leading minus sign.
+func printDuration(duration time.Duration) string {
+	return formatDuration(duration, false)
+}
+
+// printSignedDuration is printDuration with a plus sign on positive
+// durations too
+func printSignedDuration(duration time.Duration) string {
+	return formatDuration(duration, true)
+}
+
+func formatDuration(duration time.Duration, forceSign bool) string {
+	sign := ""
+	if duration < 0 {
+		sign = "-"
+		duration = -duration
+	} else if forceSign {
+		sign = "+"
+	}
+	duration = roundDisplay(duration, resolution())
+	days := duration.Truncate(day)
+	duration = duration - days
+	days = days / day
+	hours := duration.Truncate(time.Hour)
+	duration = duration - hours
+	hours = hours / time.Hour
+	minutes := duration.Truncate(time.Minute)
+	duration = duration - minutes
+	minutes = minutes / time.Minute
+	seconds := duration.Truncate(time.Second)
+	duration = duration - seconds
+	seconds = seconds / time.Second
+	if flags.human {
+		return sign + humanDuration(days, hours, minutes, seconds)
+	}
+	prefix := sign
+	if days > 0 {
+		prefix += fmt.Sprintf("%dd ", days)
+	}
+	if flags.seconds {
+		return fmt.Sprintf("[%s%2.2d:%2.2d:%2.2d]", prefix, hours, minutes, seconds)
+	}
+	if flags.hires {
+		milliseconds := duration.Truncate(time.Millisecond) / time.Millisecond
+		return fmt.Sprintf("[%s%2.2d:%2.2d:%2.2d.%3.3d]", prefix, hours, minutes, seconds, milliseconds)
+	}
+	milliseconds := duration.Truncate(time.Millisecond) / (time.Millisecond * 10)
+
+	return fmt.Sprintf("[%s%2.2d:%2.2d:%2.2d.%2.2d]", prefix, hours, minutes, seconds, milliseconds)
+}
+
+// humanDuration writes a duration the way people do, from the largest unit
+// that isn't zero: 1h 02m 03s, 5m 07s or 42s
+func humanDuration(days, hours, minutes, seconds time.Duration) string {
+	parts := []struct {
+		n    time.Duration
+		unit string
+	}{{days, "d"}, {hours, "h"}, {minutes, "m"}, {seconds, "s"}}
+	var b strings.Builder
+	for _, p := range parts {
+		switch {
+		case b.Len() > 0:
+			fmt.Fprintf(&b, " %2.2d%s", p.n, p.unit)
+		case p.n > 0 || p.unit == "s":
+			fmt.Fprintf(&b, "%d%s", p.n, p.unit)
+		}
+	}
+	return b.String()
+}
+
+// display holds the values available to a -format template
+type display struct {
+	Label     string
+	Mode      Mode
+	Elapsed   string
+	Remaining string
+	Total     string
+	Percent   int // of the countdown done, 0 for stopwatches
+}
+
+func newDisplay(mode Mode, total time.Duration, duration time.Duration) display {
+	d := display{
+		Label:     flags.label,
+		Mode:      mode,
+		Elapsed:   printDuration(duration),
+		Remaining: printDuration(total - duration),
+		Total:     printDuration(total),
+	}
+	if mode != STOPWATCH && total > 0 {
+		d.Percent = int(100 * float64(duration) / float64(total))
+	} else if mode != STOPWATCH {
+		d.Percent = 100
+	}
+	return d
+}
+
+// elapsedLine is the main line of the display
+func elapsedLine(mode Mode, total time.Duration, duration time.Duration) string {
+	if flags.format != nil {
+		var b strings.Builder
+		if err := flags.format.Execute(&b, newDisplay(mode, total, duration)); err != nil {
+			b.WriteString(err.Error())
+		}
+		return b.String()
+	}
+
+	label := ""
+	if flags.label != "" {
+		label = flags.label + ": "
+	}
+	percent := ""
+	if flags.percent && mode != STOPWATCH {
+		percent = fmt.Sprintf(" %3d%%", newDisplay(mode, total, duration).Percent)
+	}
+	switch mode {
+	case COUNTDOWN, ALARM:
+		remaining := color(stateColor(mode, total, duration), printDuration(total-duration))
+		if flags.dual {
+			return fmt.Sprintf("%s%s gone / %s left%s", label, printDuration(duration), remaining, percent)
+		}
+		return fmt.Sprintf("%sTime Remaining: %s%s", label, remaining, percent)
+	}
+	return fmt.Sprintf("%sElapsed time: %s%s", label, color(stateColor(mode, total, duration), printDuration(duration)), percent)
+}
+
+const (
+	compactWidth = 32 // narrower terminals get the compact display
+	tinyWidth    = 8  // narrower still and only minutes are shown
+)
+
+// compact reports whether to use the minimal display, either because
+// -compact was given or because the terminal is too narrow for the full one
+func compact() bool {
+	if flags.compact {
+		return true
+	}
+	c := screen.columns()
+	return c > 0 && c < compactWidth
+}
+
+var spinner = `|/-\`
+
+// compactLine is the time left or elapsed squeezed into a few cells: 07:32,
+// 1:07:32 or 2d03h, or a spinner and whole minutes when even that does not
+// fit
+func compactLine(mode Mode, total time.Duration, duration time.Duration) string {
+	d := duration
+	if mode == COUNTDOWN || mode == ALARM {
+		d = total - duration
+	}
+	sign := ""
+	if d < 0 {
+		sign = "-"
+		d = -d
+	}
+	var s string
+	if c := screen.columns(); c > 0 && c < tinyWidth {
+		spin := spinner[duration/time.Second%time.Duration(len(spinner))]
+		if onBattery || screen.slow() {
+			// not worth waking up for, or sending down a slow link
+			spin = ' '
+		}
+		s = fmt.Sprintf("%c%s%dm", spin, sign, roundDisplay(d, time.Minute)/time.Minute)
+	} else {
+		s = sign + shortDuration(d)
+	}
+	return color(stateColor(mode, total, duration), s)
+}
+
+// shortDuration writes d the way a clock would: 07:32, 1:07:32 or 2d03h
+func shortDuration(d time.Duration) string {
+	d = roundDisplay(d, time.Second)
+	hours := d / time.Hour
+	minutes := d % time.Hour / time.Minute
+	seconds := d % time.Minute / time.Second
+	switch {
+	case d >= day:
+		return fmt.Sprintf("%dd%2.2dh", d/day, hours%24)
+	case hours > 0:
+		return fmt.Sprintf("%d:%2.2d:%2.2d", hours, minutes, seconds)
+	}
+	return fmt.Sprintf("%2.2d:%2.2d", minutes, seconds)
+}
+
+// barWidth is the number of cells in the -bar progress bar
+const barWidth = 40
+
+// progressBar draws how much of total has passed
+func progressBar(total time.Duration, duration time.Duration) string {
+	filled := barWidth
+	if total > 0 && duration < total {
+		filled = int(barWidth * float64(duration) / float64(total))
+	}
+	full := strings.Repeat(currentTheme.barFull, filled)
+	if currentTheme.gradient && depth != noColor && !screen.slow() {
+		var b strings.Builder
+		for i := 0; i < filled; i++ {
+			b.WriteString(color(gradient(float64(i)/barWidth), currentTheme.barFull))
+		}
+		full = b.String()
+	}
+	return "[" + full + strings.Repeat(currentTheme.barEmpty, barWidth-filled) + "]"
+}
+
+// frame returns the lines showing a timer: the main line followed by any
+// extra regions that are turned on
+func frame(mode Mode, total time.Duration, duration time.Duration) []string {
+	if flags.format == nil && compact() {
+		return []string{compactLine(mode, total, duration)}
+	}
+	var lines []string
+	if flags.format == nil && flags.style != nil {
+		lines = bigFrame(mode, total, duration)
+	}
+	if lines == nil {
+		lines = []string{elapsedLine(mode, total, duration)}
+	}
+	if keys.isLocked() {
+		lines[0] += " [locked]"
+	}
+	if flags.bar && mode != STOPWATCH {
+		lines = append(lines, progressBar(total, duration))
+	}
+	if flags.analog {
+		lines = append(lines, analogFace(analogFraction(mode, total, duration))...)
+	}
+	return lines
+}
+
+// printElapsed draws a timer on the screen
+func printElapsed(mode Mode, total time.Duration, duration time.Duration) {
+	screen.draw(frame(mode, total, duration))
+}
+
+const (
+	red       = "31"
+	highlight = "7" // reverse video
+	reset     = "0"
+)
+
+// color wraps s in the escape sequences to show it in the given SGR color,
+// or returns it as it is for no color
+func color(c string, s string) string {
+	if c == "" || depth == noColor {
+		return s
+	}
+	return "\x1b[" + c + "m" + s + "\x1b[" + reset + "m"
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// dndOn is whether do not disturb was last turned on by -dnd
+var dndOn bool
+
+// dndFor is how long KDE is told do not disturb lasts, so a timer that is
+// killed without turning it off cannot leave it on for good
+const dndFor = 24 * time.Hour
+
+// setDND turns the desktop's do not disturb mode on or off. The -dnd-hook
+// command is run with "on" or "off" if there is one, otherwise GNOME is
+// asked to stop showing notification banners, or KDE Plasma to hold back
+// notifications until dndFor from now.
+func setDND(on bool) {
+	if on == dndOn {
+		return
+	}
+	state := "off"
+	if on {
+		state = "on"
+	}
+	var cmd *exec.Cmd
+	switch {
+	case flags.dndHook != "":
+		cmd = exec.Command("/bin/sh", "-c", flags.dndHook+` "$1"`, "sh", state)
+	case strings.Contains(strings.ToUpper(os.Getenv("XDG_CURRENT_DESKTOP")), "GNOME"):
+		cmd = exec.Command("gsettings", "set", "org.gnome.desktop.notifications", "show-banners", fmt.Sprint(!on))
+	case strings.Contains(strings.ToUpper(os.Getenv("XDG_CURRENT_DESKTOP")), "KDE"):
+		cmd = plasmaDND(on)
+	default:
+		if flags.verbose {
+			screen.print("No way to change do not disturb, use -dnd-hook\n")
+		}
+		return
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		screen.print(fmt.Sprintf("Unable to turn do not disturb %s: %v %s\n", state, err, strings.TrimSpace(string(out))))
+		return
+	}
+	dndOn = on
+}
+
+// plasmaDND sets when Plasma's do not disturb ends, which is kept in
+// plasmanotifyrc and read again by the shell when kwriteconfig notifies it
+func plasmaDND(on bool) *exec.Cmd {
+	kwriteconfig := "kwriteconfig5"
+	if _, err := exec.LookPath("kwriteconfig6"); err == nil {
+		kwriteconfig = "kwriteconfig6"
+	}
+	args := []string{"--file", "plasmanotifyrc", "--group", "DoNotDisturb", "--key", "Until", "--notify"}
+	if !on {
+		return exec.Command(kwriteconfig, append(args, "--delete")...)
+	}
+	until := time.Now().Add(dndFor)
+	return exec.Command(kwriteconfig, append(args, until.Format("2006,1,2,15,4,5"))...)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// eventStream writes what happens to a timer as newline delimited JSON for
+// -events, so other programs can follow it as it runs. It gets every event
+// the recorder does, with ticks cut down to one a second.
+type eventStream struct {
+	mu       sync.Mutex
+	w        io.Writer
+	lastTick time.Time
+}
+
+// event is a line of the stream
+type event struct {
+	Time  time.Time `json:"time"`
+	Event string    `json:"event"`
+	Value string    `json:"value,omitempty"`
+}
+
+// events is where -events writes, nil without it
+var events *eventStream
+
+// eventTick is how often ticks go into the stream
+const eventTick = time.Second
+
+// openEvents starts a stream to path, stdout for -
+func openEvents(path string) (*eventStream, error) {
+	if path == "-" {
+		return &eventStream{w: os.Stdout}, nil
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &eventStream{w: f}, nil
+}
+
+// emit writes an event, the exit of a timer as its finish
+func (s *eventStream) emit(kind, value string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	switch kind {
+	case "key":
+		return
+	case "tick":
+		if now.Sub(s.lastTick) < eventTick {
+			return
+		}
+		s.lastTick = now
+	case "exit":
+		kind = "finish"
+	}
+	b, err := json.Marshal(event{Time: now, Event: kind, Value: value})
+	if err != nil {
+		return
+	}
+	s.w.Write(append(b, '\n'))
+}
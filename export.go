@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// exporters write a finished stopwatch session and its laps for -export
+var exporters = map[string]func(w io.Writer, t *timer){
+	"md": exportMarkdown,
+}
+
+// exportMarkdown writes the session as a Markdown table, ready to paste into
+// an issue or notes
+func exportMarkdown(w io.Writer, t *timer) {
+	title := "Stopwatch"
+	if flags.label != "" {
+		title = mdEscape(flags.label)
+	}
+	fmt.Fprintf(w, "**%s**, %s, %s\n", title, t.began.Format("2006-01-02 15:04"), mdTime(t.elapsed))
+	if t.pauses > 0 {
+		fmt.Fprintf(w, "\n%s\n", t.pauseSummary())
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "| Lap | Split | Cumulative | Note |")
+	fmt.Fprintln(w, "| ---: | ---: | ---: | --- |")
+	prev := t.offset
+	for i, l := range t.laps {
+		note := mdEscape(l.note)
+		if l.auto && note == "" {
+			note = "(auto)"
+		}
+		fmt.Fprintf(w, "| %d | %s | %s | %s |\n", i+1, mdTime(l.at-prev), mdTime(l.at), note)
+		prev = l.at
+	}
+	// the time after the last lap is a lap of its own
+	if t.elapsed > prev {
+		fmt.Fprintf(w, "| %d | %s | %s | (end) |\n", len(t.laps)+1, mdTime(t.elapsed-prev), mdTime(t.elapsed))
+	}
+}
+
+func mdTime(d time.Duration) string {
+	return strings.Trim(printDuration(d), "[]")
+}
+
+// mdEscape keeps text from breaking out of a table cell
+func mdEscape(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+}
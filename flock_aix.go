@@ -0,0 +1,40 @@
+package main
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// AIX has no flock, so lockFile and the rest take fcntl locks on the whole
+// file instead, which keep other instances of gutimer out just the same
+
+func fcntlLock(f *os.File, how int16, cmd int) error {
+	lk := unix.Flock_t{Type: how}
+	for {
+		err := unix.FcntlFlock(f.Fd(), cmd, &lk)
+		if err != unix.EINTR {
+			return err
+		}
+	}
+}
+
+func lockFile(f *os.File, exclusive bool) error {
+	how := int16(unix.F_RDLCK)
+	if exclusive {
+		how = unix.F_WRLCK
+	}
+	return fcntlLock(f, how, unix.F_SETLKW)
+}
+
+func unlockFile(f *os.File) error {
+	return fcntlLock(f, unix.F_UNLCK, unix.F_SETLK)
+}
+
+func tryLockFile(f *os.File) (bool, error) {
+	err := fcntlLock(f, unix.F_WRLCK, unix.F_SETLK)
+	if err == unix.EAGAIN || err == unix.EACCES {
+		return false, nil
+	}
+	return err == nil, err
+}
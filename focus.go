@@ -0,0 +1,29 @@
+package main
+
+// focus carries the focus reports of the terminal to the run loop for
+// -focus-pause: true as it gains focus and false as it loses it
+var focus = make(chan bool)
+
+// the terminal reports focus as ESC [ I and ESC [ O once asked to, which
+// the decoder reads as focus-in and focus-out
+const (
+	reportFocus   = "\x1b[?1004h"
+	unreportFocus = "\x1b[?1004l"
+)
+
+// focusChanged pauses a stopwatch when the terminal loses focus and
+// carries on when it gets it back, unless it was paused by hand
+func (t *timer) focusChanged(in bool) {
+	if t.mode != STOPWATCH {
+		return
+	}
+	switch {
+	case !in && !t.paused:
+		t.pause()
+		t.unfocused = true
+	case in && t.unfocused:
+		t.unfocused = false
+		t.resume()
+	}
+	t.draw()
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// how often -git-laps looks for new commits
+const gitInterval = time.Second
+
+// gitReflog is the reflog of HEAD in the repository dir, which git adds a
+// line to for every commit
+func gitReflog(dir string) (string, error) {
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "--absolute-git-dir").Output()
+	if err != nil {
+		return "", fmt.Errorf("%s is not a git repository", dir)
+	}
+	return filepath.Join(strings.TrimSpace(string(out)), "logs", "HEAD"), nil
+}
+
+// reflogCommit returns the short hash and subject of a commit from a line
+// of the reflog, or false for a checkout, reset and the like. A line is
+// "old new who when\tcommit: subject", or commit (amend) and so on.
+func reflogCommit(line string) (string, bool) {
+	tab := strings.IndexByte(line, '\t')
+	if tab < 0 {
+		return "", false
+	}
+	msg := line[tab+1:]
+	if !strings.HasPrefix(msg, "commit") {
+		return "", false
+	}
+	colon := strings.Index(msg, ": ")
+	if colon < 0 {
+		return "", false
+	}
+	fields := strings.Fields(line[:tab])
+	if len(fields) < 2 || len(fields[1]) < 7 {
+		return "", false
+	}
+	return fields[1][:7] + " " + msg[colon+2:], true
+}
+
+// watchGit takes a lap of the stopwatch with a note of the commit whenever
+// something is committed, by reading what is added to the end of the
+// reflog. Commits from before the start are left out.
+func watchGit(ctx context.Context, reflog string, t *timer) {
+	var offset int64
+	if fi, err := os.Stat(reflog); err == nil {
+		offset = fi.Size()
+	}
+	tk := time.NewTicker(gitInterval)
+	defer tk.Stop()
+	var partial []byte
+	for {
+		select {
+		case <-tk.C:
+		case <-ctx.Done():
+			return
+		}
+		f, err := os.Open(reflog)
+		if err != nil {
+			continue
+		}
+		if fi, err := f.Stat(); err == nil && fi.Size() < offset {
+			// expired and written again, so start over from its end
+			offset, partial = fi.Size(), nil
+		}
+		f.Seek(offset, io.SeekStart)
+		b, _ := ioutil.ReadAll(f)
+		f.Close()
+		offset += int64(len(b))
+		b = append(partial, b...)
+		end := bytes.LastIndexByte(b, '\n')
+		// a line still being written is left for next time
+		partial = append([]byte(nil), b[end+1:]...)
+		if end < 0 {
+			continue
+		}
+		for _, line := range strings.Split(string(b[:end]), "\n") {
+			commit, ok := reflogCommit(line)
+			if !ok {
+				continue
+			}
+			for _, cmd := range []string{"lap", "note " + commit} {
+				select {
+				case t.commands <- cmd:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}
+}
@@ -1,3 +1,8 @@
 module github.com/McKayJT/gutimer
 
-require github.com/pkg/term v0.0.0-20190109203006-aa71e9d9e942
+go 1.17
+
+require (
+	golang.org/x/sys v0.5.0
+	golang.org/x/term v0.5.0
+)
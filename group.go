@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// groupRefresh is how often a running timer in a -group reads the history
+// again, picking up other timers of the group that have ended since
+const groupRefresh = 30 * time.Second
+
+// groupTotals adds up the elapsed time of the history entries in each
+// group that ended after since. The time a -name stopwatch carries on from
+// is left out, as it is in the history already, but -offset counts.
+func groupTotals(entries []historyEntry, since time.Time) map[string]time.Duration {
+	totals := map[string]time.Duration{}
+	for _, entry := range entries {
+		if entry.Group != "" && entry.End.After(since) {
+			totals[entry.Group] += time.Duration((entry.Elapsed - entry.Offset) * float64(time.Second))
+		}
+	}
+	return totals
+}
+
+// groupLine shows the combined time of the -group of the timer: the
+// history of the group with the time on this one
+func (t *timer) groupLine() string {
+	if now := time.Now(); now.Sub(t.groupRead) >= groupRefresh {
+		t.groupRead = now
+		if entries, err := readHistory(); err == nil {
+			t.groupBase = groupTotals(entries, time.Time{})[flags.group]
+		}
+	}
+	return fmt.Sprintf("%s: %s in total", flags.group, printDuration(t.groupBase+t.elapsed-t.carried))
+}
+
+// printGroups lists the time spent on each group since since
+func printGroups(entries []historyEntry, since time.Time) {
+	totals := groupTotals(entries, since)
+	var names []string
+	for name := range totals {
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return
+	}
+	sort.Strings(names)
+	fmt.Println()
+	for _, name := range names {
+		fmt.Printf("%s  %s\n", printDuration(totals[name]), name)
+	}
+}
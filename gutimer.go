@@ -1,11 +1,17 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
-	"github.com/pkg/term"
+	"io"
 	"os"
+	"strconv"
+	"text/template"
 	"time"
+
+	"golang.org/x/term"
 )
 
 type Mode int
@@ -15,144 +21,1130 @@ const (
 	TIMER
 	COUNTDOWN
 	STOPWATCH
+	ALARM
+	RECUR
+	RACE
+	POMODORO
+	ROUTINE
 )
 
+var modeNames = map[Mode]string{
+	NONE:      "none",
+	TIMER:     "timer",
+	COUNTDOWN: "countdown",
+	STOPWATCH: "stopwatch",
+	ALARM:     "alarm",
+	RECUR:     "recur",
+	RACE:      "race",
+	POMODORO:  "pomodoro",
+	ROUTINE:   "routine",
+}
+
+func (m Mode) String() string {
+	if name, ok := modeNames[m]; ok {
+		return name
+	}
+	return fmt.Sprintf("Mode(%d)", int(m))
+}
+
+func parseMode(s string) (Mode, error) {
+	for m, name := range modeNames {
+		if name == s {
+			return m, nil
+		}
+	}
+	return NONE, fmt.Errorf("unknown mode %q", s)
+}
+
 type Flags struct {
-	verbose bool
-	quiet   bool
+	verbose      bool
+	quiet        bool
+	speed        float64
+	record       string
+	hires        bool
+	round        string
+	serve        string
+	ntp          string
+	ntpFix       bool
+	alarmAt      time.Time // when -a goes off, after -ntp-fix
+	every        *schedule
+	batch        bool
+	detach       bool
+	seconds      bool
+	label        string
+	overtime     bool
+	lock         bool
+	confirm      bool
+	offset       time.Duration
+	startPaused  bool
+	after        time.Duration
+	watchPid     int
+	limit        time.Duration
+	autosplit    time.Duration
+	export       string
+	name         string
+	group        string
+	media        string
+	onFinish     string
+	textFile     *textFile
+	udpListen    string
+	keepRunning  bool
+	print        string
+	hard         bool
+	maxPause     time.Duration
+	human        bool
+	events       string
+	routine      []phase
+	work         time.Duration
+	short        time.Duration
+	long         time.Duration
+	cycles       int
+	history      bool
+	goal         int
+	dayStart     timeOfDay
+	dnd          bool
+	refresh      time.Duration
+	breakScreen  bool
+	notify       bool
+	speak        bool
+	sound        string
+	warnings     warnings
+	focusPause   bool
+	mouse        bool
+	gitLaps      string
+	stopWhen     string
+	dndHook      string
+	command      []string // run by `gutimer run`
+	runs         int
+	json         bool
+	wait         bool
+	percent      bool
+	dual         bool
+	compact      bool
+	batterySaver bool
+	analog       bool
+	style        renderer // nil for the plain one line display
+	bar          bool
+	format       *template.Template
 }
 
-var flags = Flags{}
+var flags = Flags{speed: 1}
+
+// cleanup functions registered with atExit, run in reverse order by shutdown
+var cleanups []func()
+
+func atExit(f func()) {
+	cleanups = append(cleanups, f)
+}
+
+// shutdown runs all cleanup functions and exits with the given code.
+// everything that needs to happen before the process goes away (restoring
+// the terminal, saving state, running hooks) should be registered with atExit
+// rather than done inline so there is exactly one exit path.
+func shutdown(code int) {
+	for i := len(cleanups) - 1; i >= 0; i-- {
+		cleanups[i]()
+	}
+	os.Exit(code)
+}
 
 func main() {
+	if len(os.Args) == 1 && canPick() {
+		args, offered, err := menu()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to offer presets: %v\n", err)
+			os.Exit(1)
+		}
+		if offered && args == nil {
+			os.Exit(1)
+		}
+		os.Args = append(os.Args, args...)
+	}
+	if len(os.Args) > 1 && os.Args[1] == "again" {
+		args, err := againArgs(os.Args[2:])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to start again: %v\n", err)
+			os.Exit(1)
+		}
+		os.Args = append(os.Args[:1], args...)
+	}
+	if len(os.Args) > 1 {
+		args, ok, err := lookupPreset(os.Args[1], os.Args[2:])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to read presets: %v\n", err)
+			os.Exit(1)
+		}
+		if ok {
+			os.Args = append(os.Args[:1], args...)
+		}
+	}
+	startArgs = append([]string(nil), os.Args[1:]...)
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "replay":
+			os.Exit(replay(os.Args[2:]))
+		case "join":
+			os.Exit(join(os.Args[2:]))
+		case "systemd-export":
+			os.Exit(systemdExport(os.Args[2:]))
+		case "next":
+			runMode(parseNext(os.Args[2:]))
+		case "run":
+			runMode(parseRun(os.Args[2:]))
+		case "routine":
+			runMode(parseRoutine(os.Args[2:]))
+		case "resume":
+			startArgs = nil
+			runMode(parseResume(os.Args[2:]))
+		case "attach":
+			os.Exit(attach(os.Args[2:]))
+		case "stats":
+			os.Exit(stats(os.Args[2:]))
+		case "last":
+			os.Exit(last(os.Args[2:]))
+		case "selftest":
+			os.Exit(selftest(os.Args[2:]))
+		case "preset":
+			os.Exit(preset(os.Args[2:]))
+		case "simulate":
+			os.Exit(simulateCommand(os.Args[2:]))
+		}
+	}
+
 	mode, duration := parseFlags()
+	if flags.detach {
+		os.Exit(detach(&detachedTimer{Args: handoffArgs(startArgs)}))
+	}
+	runMode(mode, duration)
+}
+
+// runMode sets up the terminal and runs a timer in the given mode, then
+// exits
+func runMode(mode Mode, duration time.Duration) {
 	if flags.verbose {
-		fmt.Printf("Flags: %+v\n", flags)
-		fmt.Printf("Mode: %v\n", mode)
-		fmt.Printf("Duration: %v\n", duration)
+		fmt.Fprintf(os.Stderr, "Flags: %+v\n", flags)
+		fmt.Fprintf(os.Stderr, "Mode: %v\n", mode)
+		fmt.Fprintf(os.Stderr, "Duration: %v\n", duration)
 	}
-	c := make(chan byte)
+	// registered first so it runs last, once the terminal and everything
+	// else the timer had is let go of
+	atExit(func() {
+		if handoff != nil {
+			detach(handoff)
+		}
+	})
+	if len(startArgs) > 0 {
+		if err := keepAgain(startArgs); err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to keep the command line for gutimer again: %v\n", err)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	atExit(cancel)
+	c := make(chan key)
 	e := make(chan int)
+	go handleSignals(ctx, e)
 
-	// put terminal into cbreak mode so we get characters as they are entered
-	t, err := term.Open("/dev/tty")
-	if err != nil {
-		fmt.Printf("Unable to open terminal: %v\n", err)
-		os.Exit(1)
+	if f, ok := screen.w.(*os.File); flags.batch && ok && !term.IsTerminal(int(f.Fd())) {
+		// a frame every refresh would flood a log, like the journal of a
+		// systemd unit
+		screen.hidden = true
 	}
-	err = t.SetCbreak()
-	if err != nil {
-		fmt.Printf("Unable to set cbreak mode in terminal: %v\n", err)
-		os.Exit(1)
+	if !flags.batch {
+		// put terminal into cbreak mode so we get characters as they are entered
+		tty, err := openTerminal()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to set up terminal: %v\n", err)
+			shutdown(1)
+		}
+		atExit(func() { tty.restore() })
+		if keysFromTerminal {
+			keyboard = tty.f
+		}
+		// so a paste can't be taken for keys
+		io.WriteString(screen.w, bracketPaste)
+		atExit(func() { io.WriteString(screen.w, unbracketPaste) })
+		if flags.focusPause {
+			io.WriteString(screen.w, reportFocus)
+			atExit(func() { io.WriteString(screen.w, unreportFocus) })
+		}
+		if flags.mouse {
+			io.WriteString(screen.w, reportMouse)
+			atExit(func() { io.WriteString(screen.w, unreportMouse) })
+		}
+	}
+
+	var clock Clock = realClock{}
+	if flags.hard {
+		clock = deadlineClock{}
+	}
+	if flags.speed != 1 {
+		clock = newScaledClock(clock, flags.speed)
+	}
+
+	var rec *recorder
+	if flags.record != "" {
+		var err error
+		rec, err = newRecorder(flags.record, clock, mode, duration)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to record session: %v\n", err)
+			shutdown(1)
+		}
+		atExit(func() {
+			if err := rec.Close(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error writing recording: %v\n", err)
+			}
+		})
+	}
+
+	if flags.events != "" {
+		var err error
+		if events, err = openEvents(flags.events); err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to write events: %v\n", err)
+			shutdown(1)
+		}
+	}
+
+	if !flags.batch {
+		if flags.lock {
+			keys.lock()
+		}
+		go readStdin(ctx, c, e)
+	}
+
+	if mode == RECUR {
+		shutdown(runSchedule(ctx, clock, flags.every, duration, c, e))
+	}
+	if mode == RACE {
+		phases := racePhases(duration)
+		if flags.after > 0 {
+			phases = append([]phase{delayPhase(flags.after)}, phases...)
+		}
+		ret, _ := runPhases(ctx, clock, phases, rec, c, e)
+		shutdown(ret)
+	}
+	if mode == ROUTINE {
+		ret, _ := runPhases(ctx, clock, flags.routine, rec, c, e)
+		shutdown(ret)
+	}
+	if mode == POMODORO {
+		if flags.dnd {
+			atExit(func() { setDND(false) })
+		}
+		shutdown(runPomodoro(ctx, clock, rec, c, e))
+	}
+	if flags.after > 0 {
+		if ret, quit := runPhases(ctx, clock, []phase{delayPhase(flags.after)}, rec, c, e); quit {
+			shutdown(ret)
+		}
+		rec.event("phase", fmt.Sprintf("%v %v", mode, duration))
+	}
+	if flags.command != nil {
+		shutdown(runCommand(ctx, clock, rec, c, e))
+	}
+
+	t := newTimer(clock, mode, duration)
+	t.rec = rec
+	t.offset = flags.offset
+	if mode == ALARM {
+		t.target = flags.alarmAt
+	}
+	if flags.name != "" {
+		sw, err := openStopwatch(flags.name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to open stopwatch: %v\n", err)
+			shutdown(1)
+		}
+		t.carried = sw.elapsed()
+		t.offset += t.carried
+		go sw.checkpoint(ctx, t)
+		atExit(func() {
+			if err := sw.save(t.elapsed); err != nil {
+				fmt.Fprintf(os.Stderr, "Unable to save stopwatch %s: %v\n", sw.name, err)
+			}
+			sw.close()
+		})
+	}
+	t.waiting = flags.startPaused || flags.wait
+	if flags.serve != "" {
+		if err := serveSync(ctx, flags.serve, t); err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to serve timer: %v\n", err)
+			shutdown(1)
+		}
+	}
+
+	if flags.udpListen != "" {
+		if err := listenUDP(ctx, flags.udpListen, t); err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to listen for commands: %v\n", err)
+			shutdown(1)
+		}
 	}
-	defer t.Restore()
 
-	go readStdin(c, e)
-	ret := runTimer(mode, duration, c, e)
-	t.Restore()
-	os.Exit(ret)
+	go handleCommandSignals(ctx, t)
+	if flags.watchPid != 0 {
+		go watchPid(ctx, flags.watchPid, e)
+	}
+	if flags.stopWhen != "" {
+		if err := stopWhen(ctx, flags.stopWhen, e); err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to watch %s: %v\n", flags.stopWhen, err)
+			shutdown(1)
+		}
+	}
+	if flags.gitLaps != "" {
+		reflog, err := gitReflog(flags.gitLaps)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to watch commits: %v\n", err)
+			shutdown(1)
+		}
+		go watchGit(ctx, reflog, t)
+	}
+
+	ret := t.run(ctx, c, e)
+	if t.detached {
+		// the copy carrying on reports on it when it ends
+		shutdown(ret)
+	}
+	if flags.watchPid != 0 && !processAlive(flags.watchPid) {
+		fmt.Fprintf(os.Stderr, "Process %d exited after %s\n", flags.watchPid, printDuration(t.elapsed))
+	}
+	if p := t.pauseSummary(); p != "" {
+		fmt.Fprintln(os.Stderr, p)
+	}
+	if flags.export != "" {
+		exporters[flags.export](os.Stdout, t)
+	} else {
+		printResult(t.elapsed)
+	}
+	if flags.onFinish != "" && t.completed {
+		finishAction()
+	}
+	t.keepResume()
+	shutdown(ret)
 }
 
-func runTimer(mode Mode, duration time.Duration, c chan byte, e chan int) int {
-	start := time.Now()
+// timer is the state of a single run
+type timer struct {
+	clock     Clock
+	rec       *recorder
+	mode      Mode
+	duration  time.Duration
+	start     time.Time
+	elapsed   time.Duration
+	paused    bool
+	quit      bool
+	overdue   bool          // counting on past the end with -overtime
+	asking    bool          // waiting for an answer to the -confirm prompt
+	offset    time.Duration // counted as elapsed before the start
+	carried   time.Duration // of the offset, what a -name stopwatch had before
+	detached  bool          // handed over to another copy, which keeps it
+	target    time.Time     // when an alarm goes off, which sets duration
+	waiting   bool          // held at the start until a key is pressed
+	limited   bool          // a stopwatch went past its -limit
+	laps      []lap         // the laps of a stopwatch so far
+	mouseAt   key           // a mouse report waiting to learn where the cursor is
+	phased    bool          // one of several phases, so n and b move between them
+	jump      int           // 1 to skip to the next phase, -1 to go back
+	overview  []string      // the phases around this one, drawn under it
+	kind      string        // what the history calls this timer, like work
+	began     time.Time     // wall clock time the run started
+	completed bool          // a countdown ran to its end
+	overlay   bool          // drawn over the whole screen
+	count     int           // typed in front of a key that takes one
+	nextSplit time.Duration // when -autosplit records the next lap
+	groupBase time.Duration // the time of the -group in the history
+	groupRead time.Time     // when groupBase was last read
+	batteryAt time.Time     // when the power supply was last checked
+	frozen    bool          // space froze the display of a -hard countdown
+	frozenAt  time.Duration // the time shown while frozen
+	pausedAt  time.Time     // when the stopwatch was last paused
+	pausedFor time.Duration // time spent paused before that
+	pauses    int           // times the stopwatch was paused
+	lastLeft  time.Duration // time left at the last tick, for -warn
+	unfocused bool          // paused by -focus-pause
+
+	// a prompt asks for a line of input under the timer, like : for a
+	// command, and hands what was typed to submit on enter
+	prompt string
+	input  string
+	submit func(string) (bool, error)
+
+	// output is printed above the display, and an exit code on done ends
+	// the run: how a command run by `gutimer run` reports back
+	output   chan string
+	done     chan int
+	finished bool // a code came in on done
+
+	// other goroutines ask the run loop for its state through here, and
+	// run commands like those typed at the : prompt
+	statusReq chan chan status
+	commands  chan string
+}
+
+// status is a snapshot of a running timer
+type status struct {
+	Mode     Mode
+	Duration time.Duration
+	Elapsed  time.Duration
+	Paused   bool
+}
+
+func newTimer(clock Clock, mode Mode, duration time.Duration) *timer {
 	if mode == STOPWATCH {
 		duration = 1<<63 - 1 // duration is really an int64
 	}
-	pause := false
-	tk := time.NewTicker(time.Millisecond * 10)
-	defer tk.Stop()
+	// everything else is timed by the monotonic clock, which a clock being
+	// set doesn't move, but an alarm is for a time on the wall clock and
+	// has to follow it when NTP steps it or the machine wakes from sleep
+	if _, ok := clock.(realClock); ok && mode == ALARM {
+		clock = deadlineClock{}
+	}
+	return &timer{
+		clock:     clock,
+		mode:      mode,
+		duration:  duration,
+		statusReq: make(chan chan status),
+		commands:  make(chan string),
+	}
+}
+
+// status returns the current state of the timer. Only called from the run
+// loop; everything else goes through query.
+func (t *timer) status() status {
+	elapsed := t.elapsed
+	if !t.paused {
+		elapsed = t.clock.Now().Sub(t.start)
+		if elapsed > t.duration && !flags.overtime {
+			elapsed = t.duration
+		}
+	}
+	return status{Mode: t.mode, Duration: t.duration, Elapsed: elapsed, Paused: t.paused}
+}
+
+// query asks the run loop for the timer's status
+func (t *timer) query(ctx context.Context) (status, bool) {
+	reply := make(chan status, 1)
+	select {
+	case t.statusReq <- reply:
+	case <-ctx.Done():
+		return status{}, false
+	}
+	return <-reply, true
+}
+
+func (t *timer) run(ctx context.Context, c chan key, e chan int) int {
+	// tick once per displayed unit so every change of the last digit is
+	// drawn, and measure the time as late as possible before writing it
+	interval := refreshInterval()
+	tk := t.clock.NewTicker(interval)
+	defer func() { tk.Stop() }()
+	t.began = time.Now()
+	defer t.remember()
+	defer t.keepLast()
+	t.start = t.clock.Now().Add(-t.offset)
+	t.batteryAt = t.clock.Now()
+	// an alarm is timed from the start to its target rather than for the
+	// time there was to it when it was parsed, before the -ntp check and
+	// setting up the terminal
+	if !t.target.IsZero() {
+		t.duration = t.target.Sub(t.start)
+		if t.duration < 0 {
+			t.duration = 0
+		}
+	}
+	// warnings already passed at the start stay quiet
+	t.lastLeft = t.duration - t.offset
+	// a timer held at the start by -wait starts when a key is pressed
+	if !t.waiting {
+		events.emit("start", t.offset.String())
+	}
+	if t.offset != 0 {
+		t.rec.event("offset", t.offset.String())
+	}
+	if flags.autosplit > 0 {
+		t.nextSplit = (t.offset/flags.autosplit + 1) * flags.autosplit
+	}
+	// draw straight away rather than leaving the line empty until the first
+	// tick, which can be a second away
+	if t.waiting {
+		t.paused = true
+		t.elapsed = t.offset
+		t.draw()
+	} else if t.tick() {
+		t.rec.event("exit", "0")
+		t.finish()
+		return 0
+	}
 
-LOOP:
-	for d := time.Since(start); ; {
+	// a command or going on or off battery can change how often to redraw
+	retick := func() {
+		if iv := refreshInterval(); iv != interval {
+			tk.Stop()
+			tk = t.clock.NewTicker(iv)
+			interval = iv
+		}
+	}
+
+	for {
 		select {
-		case t := <-tk.C:
-			if pause {
-				continue
+		case <-tk.C():
+			if t.recheckBattery() {
+				retick()
 			}
-			d = t.Sub(start)
-			if d > duration {
-				fmt.Print("\a")
-				printElapsed(mode, duration, duration)
-				break LOOP
+			if t.paused {
+				t.checkPause()
+				continue
 			}
-			printElapsed(mode, duration, d)
-		case char := <-c:
-			if char == 'Q' || char == 'q' {
-				break LOOP
+			if t.tick() {
+				t.rec.event("exit", "0")
+				t.finish()
+				return 0
 			}
-			if mode == STOPWATCH && char == ' ' {
-				if !pause {
-					pause = true
-				} else {
-					start = time.Now().Add(-d)
-					pause = false
+		case k := <-c:
+			t.rec.key(k)
+			quit := t.press(k)
+			retick()
+			if quit {
+				t.quit = true
+				t.rec.event("exit", "0")
+				t.finish()
+				if t.overdue {
+					fmt.Fprintf(os.Stderr, "Overtime: %s\n", color(currentTheme.overtime, printSignedDuration(t.elapsed-t.duration)))
 				}
+				return 0
+			}
+		case in := <-focus:
+			t.focusChanged(in)
+		case reply := <-t.statusReq:
+			reply <- t.status()
+		case line := <-t.commands:
+			quit, err := t.exec(line)
+			if err != nil {
+				screen.print(fmt.Sprintf("%v\n", err))
+				t.draw()
 			}
+			if quit {
+				t.quit = true
+				t.rec.event("exit", "0")
+				t.finish()
+				return 0
+			}
+		case s := <-t.output:
+			screen.print(s)
+			t.draw()
+		case ret := <-t.done:
+			t.finished = true
+			t.elapsed = t.clock.Now().Sub(t.start)
+			t.draw()
+			t.rec.event("exit", strconv.Itoa(ret))
+			t.finish()
+			return ret
 		case ret := <-e:
+			if ret == detachCode {
+				err := t.handoff()
+				if err == nil {
+					t.rec.event("detach", "")
+					t.finish()
+					return 0
+				}
+				screen.print(fmt.Sprintf("Unable to detach: %v\n", err))
+				ret = 1
+			}
+			t.rec.event("exit", strconv.Itoa(ret))
+			t.quit = true
+			t.finish()
 			return ret
+		case <-ctx.Done():
+			t.finish()
+			return 1
 		}
 	}
-	fmt.Print("\n")
-	return 0
 }
 
-func printDuration(duration time.Duration) string {
-	hours := duration.Truncate(time.Hour)
-	duration = duration - hours
-	hours = hours / time.Hour
-	minutes := duration.Truncate(time.Minute)
-	duration = duration - minutes
-	minutes = minutes / time.Minute
-	seconds := duration.Truncate(time.Second)
-	duration = duration - seconds
-	seconds = seconds / time.Second
-	milliseconds := duration.Truncate(time.Millisecond) / (time.Millisecond * 10)
+// tick updates the display and reports whether the run is over
+func (t *timer) tick() bool {
+	t.elapsed = t.clock.Now().Sub(t.start)
+	t.autosplit()
+	if t.mode != STOPWATCH {
+		t.checkWarnings()
+	}
+	if t.elapsed > t.duration && flags.overtime && t.mode != STOPWATCH {
+		if !t.overdue {
+			t.alert()
+			t.overdue = true
+		}
+	} else if t.mode == STOPWATCH && flags.limit > 0 && t.elapsed > flags.limit && !t.limited {
+		screen.bell()
+		t.rec.event("limit", flags.limit.String())
+		t.limited = true
+	} else if t.elapsed > t.duration {
+		t.alert()
+		t.elapsed = t.duration
+		t.completed = true
+		t.frozen = false
+		t.draw()
+		t.rec.tick(t.elapsed)
+		return true
+	}
+	t.draw()
+	t.rec.tick(t.elapsed)
+	return false
+}
 
-	return fmt.Sprintf("[%2.2d:%2.2d:%2.2d.%2.2d]", hours, minutes, seconds, milliseconds)
+// finish leaves the timer on screen as it really is, without the overview
+// of the phases and anything half typed, which are only of use while it
+// runs
+func (t *timer) finish() {
+	if t.overview != nil || t.count != 0 || t.frozen {
+		t.overview = nil
+		t.count = 0
+		t.frozen = false
+		t.draw()
+	}
+	screen.finish()
 }
 
-func printElapsed(mode Mode, total time.Duration, duration time.Duration) {
-	switch mode {
-	case STOPWATCH:
-		fallthrough
-	case TIMER:
-		fmt.Printf("\rElapsed time: %s", printDuration(duration))
-	case COUNTDOWN:
-		fmt.Printf("\rTime Remaining: %s", printDuration(total-duration))
+// draw shows the timer as it was at the last tick
+func (t *timer) draw() {
+	if flags.textFile != nil {
+		flags.textFile.write(t.mode, t.duration, t.elapsed)
+	}
+	if t.overlay {
+		screen.draw(breakFrame(t.mode, t.duration, t.elapsed))
+		return
+	}
+	elapsed := t.elapsed
+	if t.frozen {
+		elapsed = t.frozenAt
 	}
+	lines := frame(t.mode, t.duration, elapsed)
+	if t.frozen {
+		lines = append(lines, "Display frozen, the deadline is not")
+	}
+	if flags.group != "" {
+		lines = append(lines, t.groupLine())
+	}
+	lines = append(lines, t.overview...)
+	if t.asking {
+		lines = append(lines, "Really quit? (y/n)")
+	} else if t.prompt != "" {
+		lines = append(lines, t.prompt+t.input)
+	} else if t.count != 0 {
+		lines = append(lines, t.countLine())
+	} else if t.waiting && flags.wait {
+		lines = append(lines, "Press any key to start")
+	} else if t.waiting {
+		lines = append(lines, "Press space to start")
+	}
+	screen.draw(lines)
 }
 
-func readStdin(c chan byte, e chan int) {
-	b := make([]byte, 1)
+// key handles a character read from the terminal and reports whether the
+// user asked to quit
+func (t *timer) key(char byte) bool {
+	if t.prompt != "" {
+		return t.promptKey(char)
+	}
+	if t.asking {
+		t.asking = false
+		t.draw()
+		return char == 'y' || char == 'Y'
+	}
+	if char == 'Q' || char == 'q' {
+		if flags.confirm {
+			t.asking = true
+			t.draw()
+			return false
+		}
+		return true
+	}
+	if t.phased && (char == 'n' || char == 'b') {
+		t.jump = 1
+		if char == 'b' {
+			t.jump = -1
+		}
+		return true
+	}
+	if !t.waiting && t.countKey(char) {
+		return false
+	}
+	if char == ':' {
+		t.ask(":", t.exec)
+		return false
+	}
+	if t.waiting {
+		if char == ' ' || flags.wait {
+			t.waiting = false
+			t.start = t.clock.Now().Add(-t.elapsed)
+			t.paused = false
+			t.rec.event("start", t.elapsed.String())
+			t.draw()
+		}
+		return false
+	}
+	if t.mode == STOPWATCH && (char == 'l' || char == 'L') {
+		t.lapNow()
+		// L asks what the lap was for
+		if char == 'L' {
+			t.ask(fmt.Sprintf("Note for lap %d: ", len(t.laps)), func(s string) (bool, error) {
+				if s == "" {
+					return false, nil
+				}
+				return false, t.note(s)
+			})
+		}
+	}
+	if char == 'y' {
+		t.yank()
+	}
+	if t.mode == STOPWATCH && char == 'u' {
+		if err := t.unlap(); err != nil {
+			screen.bell()
+		}
+		t.draw()
+	}
+	// the deadline of a -hard countdown can't be paused, only the display
+	if flags.hard && t.mode != STOPWATCH && char == ' ' {
+		t.frozen = !t.frozen
+		t.frozenAt = t.elapsed
+		t.rec.event("freeze", strconv.FormatBool(t.frozen))
+		t.draw()
+	}
+	if t.mode == STOPWATCH && char == ' ' {
+		if !t.paused {
+			t.pause()
+			t.checkPause()
+		} else {
+			t.resume()
+		}
+	}
+	return false
+}
+
+// pause stops the clock of a stopwatch
+func (t *timer) pause() {
+	if t.mode != STOPWATCH || t.paused {
+		return
+	}
+	t.elapsed = t.clock.Now().Sub(t.start)
+	t.paused = true
+	t.pausedAt = t.clock.Now()
+	t.pauses++
+	t.rec.event("pause", t.elapsed.String())
+}
+
+// resume starts a paused stopwatch again where it left off
+func (t *timer) resume() {
+	if !t.paused || t.waiting {
+		return
+	}
+	t.start = t.clock.Now().Add(-t.elapsed)
+	t.paused = false
+	t.pausedFor += t.clock.Now().Sub(t.pausedAt)
+	t.rec.event("resume", t.elapsed.String())
+}
+
+// pausedTotal is all the time the stopwatch has spent paused, so far
+func (t *timer) pausedTotal() time.Duration {
+	if t.paused && !t.waiting {
+		return t.pausedFor + t.clock.Now().Sub(t.pausedAt)
+	}
+	return t.pausedFor
+}
+
+// pauseSummary says how often and how long the stopwatch was paused, none
+// if it wasn't
+func (t *timer) pauseSummary() string {
+	switch t.pauses {
+	case 0:
+		return ""
+	case 1:
+		return fmt.Sprintf("Paused once for %s", printDuration(t.pausedTotal()))
+	}
+	return fmt.Sprintf("Paused %d times for %s total", t.pauses, printDuration(t.pausedTotal()))
+}
+
+// checkPause resumes a paused stopwatch that has used up its -max-pause
+func (t *timer) checkPause() {
+	if flags.maxPause <= 0 || t.waiting {
+		return
+	}
+	if t.pausedTotal() < flags.maxPause {
+		return
+	}
+	screen.bell()
+	screen.print(fmt.Sprintf("Used up the %s of pauses, carrying on\n", printDuration(flags.maxPause)))
+	t.resume()
+	t.draw()
+}
+
+// keyboard is where keys are read from: stdin, unless stdin was taken by
+// something else, like the plan of gutimer routine -, and keysFromTerminal
+// says to read the terminal
+var (
+	keyboard         = os.Stdin
+	keysFromTerminal bool
+)
+
+// readStdin sends characters read from stdin on c until ctx is cancelled.
+// a read error or C-d sends an exit code on e and stops reading.
+func readStdin(ctx context.Context, c chan<- key, e chan<- int) {
+	buf := make([]byte, 64)
+	var d decoder
 
 	for {
-		_, err := os.Stdin.Read(b)
+		n, err := keyboard.Read(buf)
+		for _, k := range d.decode(buf[:n]) {
+			if flags.verbose {
+				if k.name != "" {
+					fmt.Fprintf(os.Stderr, "read %s from stdin\n", k.name)
+				} else {
+					fmt.Fprintf(os.Stderr, "read %q from stdin\n", k.char)
+				}
+			}
+			if k.name == "focus-in" || k.name == "focus-out" {
+				if !flags.focusPause {
+					continue
+				}
+				select {
+				case focus <- k.name == "focus-in":
+				case <-ctx.Done():
+					return
+				}
+				continue
+			}
+			if k.name != "" && keys.isLocked() {
+				continue
+			}
+			if k.name == "" && !keys.pass(k.char) {
+				continue
+			}
+			// exit if C-d recieved
+			if k.name == "" && k.char == '\x04' {
+				send(ctx, e, 0)
+				return
+			}
+			select {
+			case c <- k:
+			case <-ctx.Done():
+				return
+			}
+		}
+		if err == io.EOF && flags.keepRunning {
+			// carry on without keys, under the control of signals and
+			// -udp-listen
+			return
+		}
 		if err != nil {
-			fmt.Printf("Error reading stdin: %v\n", err)
-			e <- 1
+			fmt.Fprintf(os.Stderr, "Error reading stdin: %v\n", err)
+			send(ctx, e, 1)
+			return
 		}
-		if flags.verbose {
-			fmt.Printf("read %q from stdin\n", b[0])
+	}
+}
+
+// send delivers an exit code unless ctx is cancelled first
+func send(ctx context.Context, e chan<- int, code int) {
+	select {
+	case e <- code:
+	case <-ctx.Done():
+	}
+}
+
+// durationValue is a flag.Value for a duration in any form parseDuration
+// takes
+type durationValue time.Duration
+
+func (d *durationValue) Set(s string) error {
+	v, err := parseDuration(s)
+	*d = durationValue(v)
+	return err
+}
+
+func (d *durationValue) String() string {
+	return time.Duration(*d).String()
+}
+
+// displayFlagNames are the flags registered by displayFlags
+var displayFlagNames = map[string]bool{}
+
+// displayFlags registers the flags that change how time is shown, shared by
+// every command that draws a timer
+func displayFlags(fs *flag.FlagSet) {
+	displaySet = fs
+	before := map[string]bool{}
+	fs.VisitAll(func(f *flag.Flag) { before[f.Name] = true })
+	defer fs.VisitAll(func(f *flag.Flag) {
+		if !before[f.Name] {
+			displayFlagNames[f.Name] = true
 		}
-		// exit if C-d recieved
-		if b[0] == '\x04' {
-			e <- 0
+	})
+	fs.Var((*durationValue)(&flags.refresh), "refresh", "redraw every `interval` instead of once per displayed unit")
+	fs.BoolVar(&flags.hires, "hires", false, "show milliseconds")
+	fs.BoolVar(&flags.human, "human", false, "write times like 1h 02m 03s")
+	fs.BoolVar(&flags.percent, "percent", false, "show how much of a countdown is done")
+	fs.BoolVar(&flags.bar, "bar", false, "show a progress bar under a countdown")
+	fs.BoolVar(&flags.compact, "compact", false, "show only the time, as narrow terminals do")
+	fs.BoolVar(&flags.analog, "analog", false, "show a clock face whose hand sweeps as time passes")
+	fs.BoolVar(&flags.dual, "dual", false, "show both elapsed and remaining time of a countdown")
+	fs.Func("format", "draw the display from a text/template `template` with .Label, .Mode, .Elapsed, .Remaining, .Total and .Percent", func(s string) error {
+		var err error
+		flags.format, err = template.New("format").Parse(s)
+		return err
+	})
+	fs.StringVar(&styleName, "style", "", "draw the time in `style`: plain or seven (default plain)")
+	fs.IntVar(&styleSize, "size", 0, "size of the digits of -style (default 1)")
+	fs.StringVar(&colorName, "color", "auto", "color `depth`: auto, never, 16, 256 or truecolor")
+	fs.BoolVar(&currentTheme.gradient, "gradient", false, "color the progress bar from green to red")
+	fs.StringVar(&themeName, "theme", "", "load colors, bar and style from the theme `name` or file")
+	fs.BoolVar(&flags.batterySaver, "battery-saver", true, "redraw at most once a second when running on battery")
+	fs.StringVar(&flags.round, "round", "floor", "round the last displayed digit by `method`: floor, round or ceil")
+}
+
+// styleName and styleSize pick flags.style, falling back on the theme
+var (
+	styleName string
+	styleSize int
+	themeName string
+	colorName string
+)
+
+func checkDisplayFlags() error {
+	var err error
+	depth, err = parseColorDepth(colorName)
+	if err != nil {
+		return err
+	}
+	if themeName != "" {
+		th, err := loadTheme(themeName)
+		if err != nil {
+			return fmt.Errorf("Unable to load theme: %v", err)
+		}
+		currentTheme = th
+	}
+	if styleName == "" {
+		styleName = currentTheme.style
+	}
+	if styleSize == 0 {
+		styleSize = currentTheme.size
+	}
+	if styleSize == 0 {
+		styleSize = 1
+	}
+	if styleSize < 1 {
+		return fmt.Errorf("Digit size must be at least 1")
+	}
+	if styleName != "" && styleName != "plain" {
+		newStyle, ok := styles[styleName]
+		if !ok {
+			return fmt.Errorf("Unknown style %q", styleName)
 		}
-		c <- b[0]
+		flags.style = newStyle(styleSize)
+	}
+	switch flags.round {
+	case "floor", "round", "ceil":
+	default:
+		return fmt.Errorf("Unknown rounding method %q", flags.round)
 	}
+	checkBattery()
+	if onBattery && flags.verbose {
+		fmt.Fprintln(os.Stderr, "Running on battery, redrawing once a second")
+	}
+	return nil
 }
 
+// parseFlags parses the command line of a timer, or exits with why it
+// can't
 func parseFlags() (Mode, time.Duration) {
-	var countdown, timer, stopwatch bool
+	mode, duration, err := parseArgs()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	return mode, duration
+}
+
+// parseArgs parses os.Args into flags and returns the mode and duration
+// of the timer they ask for
+func parseArgs() (Mode, time.Duration, error) {
+	var countdown, timer, stopwatch, alarm, race, pomodoro bool
+	var every, length string
 	var mode Mode
 
 	flag.BoolVar(&flags.verbose, "v", false, "verbose")
 	flag.BoolVar(&flags.quiet, "q", false, "quiet")
+	flag.BoolVar(&flags.batch, "batch", false, "run without a terminal and ignore input")
+	flag.BoolVar(&flags.detach, "detach", false, "run in the background as with -batch and return to the shell; C-\\ detaches a running timer too, and gutimer attach brings it back")
+	flag.BoolVar(&flags.hard, "hard", false, "keep to the wall clock deadline of a countdown, through suspend, with space only freezing the display")
+	flag.BoolVar(&flags.overtime, "overtime", false, "keep counting past the end of a countdown")
+	flag.BoolVar(&flags.confirm, "confirm", false, "ask before quitting with q")
+	flag.BoolVar(&flags.lock, "lock", false, "start with keys locked until \"unlock\" is typed, C-k locks them again")
+	flag.Var((*durationValue)(&flags.offset), "offset", "start as if the timer had already been running for `duration`")
+	flag.Var((*durationValue)(&flags.after), "after", "count down `duration` before the timer starts")
+	flag.IntVar(&flags.watchPid, "watch-pid", 0, "run a stopwatch until process `pid` exits")
+	flag.Var((*durationValue)(&flags.limit), "limit", "ring once and change color when a stopwatch passes `duration`")
+	flag.StringVar(&flags.stopWhen, "stop-when", "", "run a stopwatch until `path` appears or changes")
+	flag.StringVar(&flags.gitLaps, "git-laps", "", "take a lap of a stopwatch at every commit in the git repository `dir`")
+	flag.BoolVar(&flags.focusPause, "focus-pause", false, "pause a stopwatch while the terminal does not have focus")
+	flag.BoolVar(&flags.mouse, "mouse", false, "click the time to pause, a lap to note it and scroll over a countdown to change it")
+	flag.Var((*durationValue)(&flags.maxPause), "max-pause", "carry on after a stopwatch has been paused for `duration` in all")
+	flag.Var((*durationValue)(&flags.autosplit), "autosplit", "record a lap of a stopwatch every `duration`, as well as with l")
+	flag.StringVar(&flags.name, "name", "", "keep the time of a stopwatch under `name`, carrying on from it next time")
+	flag.StringVar(&flags.print, "print", "", "print only the time the timer ran for on stdout, in `unit`: seconds, ms or go")
+	flag.StringVar(&flags.export, "export", "", "print a stopwatch session and its laps as `format` at the end: md")
+	flag.BoolVar(&flags.history, "history", true, "add finished timers to the history file")
+	goalFlags(flag.CommandLine)
+	flag.BoolVar(&flags.startPaused, "start-paused", false, "hold the timer at the start until space is pressed")
+	flag.BoolVar(&flags.wait, "wait", false, "hold the timer at the start until any key is pressed")
+	flag.BoolVar(&flags.notify, "notify", false, "show a desktop notification when a countdown ends")
+	flag.BoolVar(&flags.speak, "speak", false, "say out loud when a countdown ends")
+	flag.StringVar(&flags.sound, "sound", "", "play the sound `file` when a countdown ends")
+	flag.Var(&flags.warnings, "warn", "warn when `time` is left on a countdown, with a bell or as time=notify, speak or run:command (repeatable)")
+	flag.StringVar(&flags.onFinish, "on-finish", "", "`suspend`, shutdown or lock the computer when a countdown ends")
+	flag.StringVar(&flags.media, "media", "", "`pause` or play the media player when a countdown ends")
+	flag.StringVar(&flags.label, "label", "", "show `text` in front of the time")
+	flag.StringVar(&flags.group, "group", "", "count the timer toward `project`, showing the time of all its timers")
 	flag.BoolVar(&timer, "t", false, "start timer")
 	flag.BoolVar(&countdown, "c", false, "start countdown")
 	flag.BoolVar(&stopwatch, "s", false, "start stopwatch")
+	flag.BoolVar(&alarm, "a", false, "start countdown to a time of day")
+	flag.BoolVar(&race, "r", false, "count down a start delay, then start a stopwatch")
+	flag.BoolVar(&pomodoro, "p", false, "run pomodoros: work and breaks until quit")
+	flags.work, flags.short, flags.long = 25*time.Minute, 5*time.Minute, 15*time.Minute
+	flag.Var((*durationValue)(&flags.work), "work", "`duration` of a pomodoro")
+	flag.Var((*durationValue)(&flags.short), "short", "`duration` of the break after a pomodoro")
+	flag.Var((*durationValue)(&flags.long), "long", "`duration` of the break after the last pomodoro of a round")
+	flag.IntVar(&flags.cycles, "cycles", 4, "pomodoros in a round before the long break")
+	flag.BoolVar(&flags.breakScreen, "break-screen", false, "fill the terminal and lock the keys during pomodoro breaks")
+	flag.BoolVar(&flags.dnd, "dnd", false, "turn on GNOME or KDE do not disturb during pomodoros and off during breaks")
+	flag.StringVar(&flags.dndHook, "dnd-hook", "", "run `command` with on or off to change do not disturb for -dnd")
+	flag.StringVar(&every, "every", "", "run a countdown at every time in `schedule`, like \"mon-fri 10:00\"")
+	flag.StringVar(&length, "for", "0", "length of the countdown started by -every")
+	flag.StringVar(&flags.ntp, "ntp", "", "check the local clock against NTP `server` before an alarm")
+	flag.BoolVar(&flags.ntpFix, "ntp-fix", false, "correct an alarm for the clock offset reported by -ntp")
+	displayFlags(flag.CommandLine)
+	flag.Func("text-file", "keep the time written to `file`, for a text source in OBS", func(s string) error {
+		flags.textFile = &textFile{path: s}
+		return nil
+	})
+	flag.StringVar(&flags.events, "events", "", "write what happens as JSON lines to `file`, - for stdout")
+	flag.StringVar(&flags.record, "record", "", "record the session to `file` for gutimer replay")
+	flag.StringVar(&flags.serve, "serve", "", "let other instances join this timer on `address`")
+	flag.BoolVar(&flags.keepRunning, "ignore-stdin-close", false, "keep running when stdin is closed, taking commands from signals and -udp-listen")
+	flag.StringVar(&flags.udpListen, "udp-listen", "", "run commands like pause or lap sent in datagrams to `address`, like 127.0.0.1:9999")
+	flag.Float64Var(&flags.speed, "speed", 1, "run time `factor` times faster than real time")
+
+	if err := loadConfig(flag.CommandLine, true); err != nil {
+		return NONE, 0, fmt.Errorf("Unable to read config: %v", err)
+	}
+	if err := flag.CommandLine.Parse(os.Args[1:]); err != nil {
+		return NONE, 0, err
+	}
 
-	flag.Parse()
+	if flags.speed <= 0 {
+		return NONE, 0, errors.New("Speed must be greater than zero")
+	}
+	if err := checkDisplayFlags(); err != nil {
+		return NONE, 0, err
+	}
 
 	modes := 0
 	if timer {
@@ -167,21 +1159,180 @@ func parseFlags() (Mode, time.Duration) {
 		mode = STOPWATCH
 		modes++
 	}
+	if alarm {
+		mode = ALARM
+		modes++
+	}
+	if race {
+		mode = RACE
+		modes++
+	}
+	if pomodoro {
+		mode = POMODORO
+		modes++
+	}
+	if every != "" {
+		mode = RECUR
+		modes++
+	}
+	if modes == 0 && (flags.watchPid != 0 || flags.stopWhen != "") {
+		mode = STOPWATCH
+		modes++
+	}
 	if modes == 0 {
-		fmt.Println("No mode provided")
-		os.Exit(1)
+		return NONE, 0, errors.New("No mode provided")
 	}
 	if modes > 1 {
-		fmt.Println("Too many modes provided")
-		os.Exit(1)
+		return NONE, 0, errors.New("Too many modes provided")
 	}
 
-	// TODO: write custom duration parser
-	duration, err := time.ParseDuration(flag.Arg(0))
+	phased := mode == RECUR || mode == RACE || mode == POMODORO
+	if flags.offset != 0 && phased {
+		return NONE, 0, errors.New("-offset cannot be combined with -every, -r or -p")
+	}
+	if flags.limit != 0 && mode != STOPWATCH {
+		return NONE, 0, errors.New("-limit only applies to stopwatches")
+	}
+	if flags.stopWhen != "" && mode != STOPWATCH {
+		return NONE, 0, errors.New("-stop-when only applies to stopwatches")
+	}
+	if flags.gitLaps != "" && mode != STOPWATCH {
+		return NONE, 0, errors.New("-git-laps only applies to stopwatches")
+	}
+	if flags.focusPause && (mode != STOPWATCH || flags.batch) {
+		return NONE, 0, errors.New("-focus-pause only applies to stopwatches in a terminal")
+	}
+	if flags.mouse && (flags.batch || flags.detach) {
+		return NONE, 0, errors.New("-mouse needs a terminal, not -batch or -detach")
+	}
+	if len(flags.warnings) > 0 && mode == STOPWATCH {
+		return NONE, 0, errors.New("-warn only applies to countdowns")
+	}
+	if flags.media != "" && flags.media != "pause" && flags.media != "play" {
+		return NONE, 0, errors.New("-media is pause or play")
+	}
+	if flags.onFinish != "" {
+		if _, ok := finishCommands[flags.onFinish]; !ok {
+			return NONE, 0, fmt.Errorf("Unable to %s here", flags.onFinish)
+		}
+		if mode == STOPWATCH || phased || flags.overtime {
+			return NONE, 0, errors.New("-on-finish needs a countdown that ends, not -s, -every, -r, -p or -overtime")
+		}
+	}
+	if flags.name != "" {
+		if err := checkName(flags.name); err != nil {
+			return NONE, 0, err
+		}
+		if mode != STOPWATCH {
+			return NONE, 0, errors.New("-name only applies to stopwatches")
+		}
+	}
+	if flags.hard && (mode == STOPWATCH || flags.startPaused || flags.wait) {
+		return NONE, 0, errors.New("-hard needs a countdown, without -s, -start-paused or -wait")
+	}
+	if flags.print != "" {
+		if resultFormats[flags.print] == nil {
+			return NONE, 0, fmt.Errorf("Unknown unit %q for -print", flags.print)
+		}
+		if phased || flags.export != "" {
+			return NONE, 0, errors.New("-print cannot be combined with -every, -r, -p or -export")
+		}
+	}
+	if flags.events == "-" && (flags.print != "" || flags.export != "") {
+		return NONE, 0, errors.New("-events - cannot share stdout with -print or -export")
+	}
+	if flags.export != "" {
+		if _, ok := exporters[flags.export]; !ok {
+			return NONE, 0, fmt.Errorf("Unknown export format %q", flags.export)
+		}
+		if mode != STOPWATCH {
+			return NONE, 0, errors.New("-export only applies to stopwatches")
+		}
+	}
+	if flags.maxPause != 0 && mode != STOPWATCH {
+		return NONE, 0, errors.New("-max-pause only applies to stopwatches")
+	}
+	if flags.autosplit != 0 && mode != STOPWATCH {
+		return NONE, 0, errors.New("-autosplit only applies to stopwatches")
+	}
+	if flags.watchPid != 0 {
+		if mode != STOPWATCH {
+			return NONE, 0, errors.New("-watch-pid runs a stopwatch")
+		}
+		if !processAlive(flags.watchPid) {
+			return NONE, 0, fmt.Errorf("No process %d", flags.watchPid)
+		}
+	}
+	if flags.after != 0 && (mode == RECUR || mode == ALARM) {
+		return NONE, 0, errors.New("-after cannot be combined with -every or -a")
+	}
+	if (flags.startPaused || flags.wait) && (phased || flags.batch || flags.detach) {
+		return NONE, 0, errors.New("-start-paused and -wait cannot be combined with -every, -r, -p, -batch or -detach")
+	}
+	if flags.ntp != "" && mode != ALARM {
+		return NONE, 0, errors.New("-ntp only applies to alarms")
+	}
+	if flags.ntpFix && flags.ntp == "" {
+		return NONE, 0, errors.New("-ntp-fix needs an NTP server from -ntp")
+	}
+	arg := flag.Arg(0)
+	if arg == "" && defaultModes[mode.String()] {
+		var err error
+		if arg, err = modeDefault(mode); err != nil {
+			return NONE, 0, fmt.Errorf("Unable to read config: %v", err)
+		}
+	}
+	if mode == ALARM {
+		duration, err := alarmDuration(arg)
+		return mode, duration, err
+	}
+	if mode == RECUR {
+		if flags.record != "" || flags.serve != "" {
+			return NONE, 0, errors.New("-every cannot be combined with -record or -serve")
+		}
+		var err error
+		flags.every, err = parseSchedule(every, time.Local)
+		if err != nil {
+			return NONE, 0, fmt.Errorf("Parse error: %v", err)
+		}
+		duration, err := parseDuration(length)
+		if err != nil {
+			return NONE, 0, fmt.Errorf("Parse error: %v", err)
+		}
+		return mode, duration, nil
+	}
+	if phased && flags.udpListen != "" {
+		return NONE, 0, errors.New("-every, -r and -p cannot be combined with -udp-listen")
+	}
+	if (mode == RACE || mode == POMODORO) && flags.serve != "" {
+		return NONE, 0, errors.New("-r and -p cannot be combined with -serve")
+	}
+	if flags.dnd && mode != POMODORO {
+		return NONE, 0, errors.New("-dnd only applies to pomodoros")
+	}
+	if mode == POMODORO {
+		if flags.cycles < 1 {
+			return NONE, 0, errors.New("Cycles must be at least 1")
+		}
+		return mode, 0, nil
+	}
+
+	if arg == "" && mode != STOPWATCH && canPick() {
+		duration, ok, err := pickDuration()
+		if err != nil {
+			return NONE, 0, fmt.Errorf("Unable to pick a duration: %v", err)
+		}
+		if !ok {
+			return NONE, 0, errors.New("No duration picked")
+		}
+		// so gutimer again starts the same, without asking
+		startArgs = append(startArgs, duration.String())
+		return mode, duration, nil
+	}
+	duration, err := parseDuration(arg)
 	if err != nil && mode != STOPWATCH {
-		fmt.Printf("Parse error: %v\n", err)
-		os.Exit(1)
+		return NONE, 0, fmt.Errorf("Parse error: %v", err)
 	}
 
-	return mode, duration
+	return mode, duration, nil
 }
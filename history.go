@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// historyEntry is one line of the history file, written for every timer
+// that ends
+type historyEntry struct {
+	Start     time.Time    `json:"start"`
+	End       time.Time    `json:"end"`
+	Mode      string       `json:"mode"`
+	Kind      string       `json:"kind,omitempty"` // the phase of a pomodoro: work, short or long
+	Label     string       `json:"label,omitempty"`
+	Group     string       `json:"group,omitempty"`    // the project of -group
+	Duration  float64      `json:"duration,omitempty"` // seconds, none for stopwatches
+	Elapsed   float64      `json:"elapsed"`
+	Offset    float64      `json:"offset,omitempty"` // seconds a -name stopwatch carried on from
+	Completed bool         `json:"completed"`        // a countdown ran to its end
+	Pauses    int          `json:"pauses,omitempty"`
+	Paused    float64      `json:"paused,omitempty"` // seconds spent paused
+	Laps      []historyLap `json:"laps,omitempty"`
+	Args      []string     `json:"args,omitempty"` // the command line, for gutimer again
+}
+
+// historyLap is a lap of a stopwatch in the history
+type historyLap struct {
+	At   float64 `json:"at"` // seconds elapsed
+	Auto bool    `json:"auto,omitempty"`
+	Note string  `json:"note,omitempty"`
+}
+
+// dataDir is where gutimer keeps what it records, following the XDG base
+// directory spec
+func dataDir() string {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "gutimer")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".local", "share", "gutimer")
+}
+
+func historyFile() string {
+	return filepath.Join(dataDir(), "history.jsonl")
+}
+
+// appendHistory adds an entry to the history file
+func appendHistory(entry historyEntry) error {
+	path := historyFile()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(entry)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	// other instances append too, so the check for a torn line and the
+	// write go together
+	if err := lockFile(f, true); err != nil {
+		f.Close()
+		return err
+	}
+	line := append(b, '\n')
+	// start a fresh line after one a crash cut off
+	if fi, err := f.Stat(); err == nil && fi.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, fi.Size()-1); err == nil && last[0] != '\n' {
+			line = append([]byte{'\n'}, line...)
+		}
+	}
+	if err := syncLine(f, line); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// readHistory returns every entry in the history file, none if there is no
+// file yet
+func readHistory() ([]historyEntry, error) {
+	f, err := os.Open(historyFile())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if err := lockFile(f, false); err != nil {
+		return nil, err
+	}
+
+	var entries []historyEntry
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		// a line that isn't even JSON is one a crash cut off while it was
+		// being written, and is left out
+		if !json.Valid(sc.Bytes()) {
+			continue
+		}
+		var entry historyEntry
+		if err := json.Unmarshal(sc.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", historyFile(), n, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, sc.Err()
+}
+
+// remember writes the history entry for a timer that has just ended
+func (t *timer) remember() {
+	if t.detached {
+		return
+	}
+	if !flags.history {
+		return
+	}
+	// an -offset counts as time that ran before the start, but what a -name
+	// stopwatch carried on from is in the entry of the run it came from
+	entry := historyEntry{
+		Start:     t.began.Add(-(t.offset - t.carried)),
+		End:       time.Now(),
+		Mode:      t.mode.String(),
+		Kind:      t.kind,
+		Label:     flags.label,
+		Group:     flags.group,
+		Elapsed:   t.elapsed.Seconds(),
+		Offset:    t.carried.Seconds(),
+		Completed: t.completed,
+		Pauses:    t.pauses,
+		Paused:    t.pausedTotal().Seconds(),
+		Args:      startArgs,
+	}
+	if t.mode != STOPWATCH {
+		entry.Duration = t.duration.Seconds()
+	}
+	for _, l := range t.laps {
+		entry.Laps = append(entry.Laps, historyLap{At: l.at.Seconds(), Auto: l.auto, Note: l.note})
+	}
+	if err := appendHistory(entry); err != nil {
+		screen.print(fmt.Sprintf("Unable to write history: %v\n", err))
+	}
+}
+
+// goalFlags registers the flags for the daily pomodoro goal, shared by the
+// timer and gutimer stats
+func goalFlags(fs *flag.FlagSet) {
+	fs.IntVar(&flags.goal, "goal", 0, "pomodoros to aim for each day")
+	fs.Func("day-start", "`time` of day the goal starts over (default 00:00)", func(s string) error {
+		tod, err := parseClock(s)
+		flags.dayStart = tod
+		return err
+	})
+}
+
+// dayStart returns the start of the day now is in, days beginning at
+// -day-start rather than midnight
+func dayStart(now time.Time) time.Time {
+	tod := flags.dayStart
+	y, m, d := now.Date()
+	start := wallClock(y, m, d, tod.hour, tod.min, tod.sec, now.Location())
+	if start.After(now) {
+		start = wallClock(y, m, d-1, tod.hour, tod.min, tod.sec, now.Location())
+	}
+	return start
+}
+
+// pomodorosSince counts the completed pomodoros that ended after since
+func pomodorosSince(entries []historyEntry, since time.Time) int {
+	n := 0
+	for _, entry := range entries {
+		if entry.Kind == "work" && entry.Completed && entry.End.After(since) {
+			n++
+		}
+	}
+	return n
+}
+
+// goalProgress is shown with pomodoros when there is a -goal: "5/8 today"
+func goalProgress() string {
+	if flags.goal <= 0 {
+		return ""
+	}
+	entries, err := readHistory()
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%d/%d today", pomodorosSince(entries, dayStart(time.Now())), flags.goal)
+}
+
+// statsDays is how many days gutimer stats goes back
+const statsDays = 7
+
+// stats prints how many pomodoros were done each of the last few days and
+// the time spent on them, with today's progress toward the -goal, and the
+// time spent on each -group over those days
+func stats(args []string) int {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	goalFlags(fs)
+	if err := loadConfig(fs, false); err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to read config: %v\n", err)
+		return 1
+	}
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fmt.Fprintln(os.Stderr, "Usage: gutimer stats [-goal n] [-day-start time]")
+		return 1
+	}
+
+	entries, err := readHistory()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to read history: %v\n", err)
+		return 1
+	}
+
+	end := time.Now()
+	start := dayStart(end)
+	for i := 0; i < statsDays; i++ {
+		count := 0
+		var focus time.Duration
+		for _, entry := range entries {
+			if entry.Kind == "work" && entry.Completed && entry.End.After(start) && !entry.End.After(end) {
+				count++
+				focus += time.Duration(entry.Elapsed * float64(time.Second))
+			}
+		}
+		day := start.Format("Mon Jan 2")
+		if i == 0 && flags.goal > 0 {
+			fmt.Printf("%s  %d/%d pomodoros  %s\n", day, count, flags.goal, printDuration(focus))
+		} else {
+			fmt.Printf("%s  %d pomodoros  %s\n", day, count, printDuration(focus))
+		}
+		end = start
+		start = dayStart(start.Add(-time.Nanosecond))
+	}
+	printGroups(entries, end)
+	return 0
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+)
+
+// runHook runs command with the shell in the background as a phase of some
+// timers starts or ends, with what is happening in the environment:
+// GUTIMER_EVENT is start or end and GUTIMER_LABEL the label of the phase
+func runHook(command, event, label string) {
+	if command == "" {
+		return
+	}
+	cmd := exec.Command("/bin/sh", "-c", command)
+	cmd.Env = append(os.Environ(), "GUTIMER_EVENT="+event, "GUTIMER_LABEL="+label)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	start(cmd)
+}
+
+// playSound plays the sound file at path in the background
+func playSound(path string) {
+	if path == "" {
+		return
+	}
+	start(soundCommand(path))
+}
@@ -0,0 +1,370 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// vevent is the part of an iCalendar VEVENT needed to find its next
+// occurrence
+type vevent struct {
+	summary string
+	start   time.Time
+	allDay  bool
+	rrule   *rrule
+	exdates map[time.Time]bool
+}
+
+// rrule is the supported subset of an RFC 5545 recurrence rule: FREQ,
+// INTERVAL, COUNT, UNTIL and, for weekly rules, BYDAY without ordinals
+type rrule struct {
+	freq     string
+	interval int
+	count    int
+	until    time.Time
+	byday    []time.Weekday
+}
+
+// icsProperty is a single unfolded content line
+type icsProperty struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// readICS reads the events from an iCalendar stream. Recurrence overrides
+// (RECURRENCE-ID) and time zone definitions are ignored; TZID parameters are
+// looked up in the system time zone database. An event that uses a time
+// zone or recurrence rule we don't know is skipped, with a warning under -v,
+// rather than failing the whole calendar.
+func readICS(r io.Reader) ([]vevent, error) {
+	lines, err := unfoldICS(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []vevent
+	var ev *vevent
+	// the RRULE may come before DTSTART, so it is only parsed once the event
+	// ends and its start's time zone is known
+	var rule string
+	var skip error
+	depth, begin := 0, 0
+	for n, line := range lines {
+		p, err := parseICSLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n+1, err)
+		}
+		switch {
+		case p.name == "BEGIN" && p.value == "VEVENT":
+			ev = &vevent{exdates: map[time.Time]bool{}}
+			rule, skip = "", nil
+			depth, begin = 0, n+1
+		case ev == nil:
+		case p.name == "BEGIN":
+			// skip nested components like VALARM
+			depth++
+		case p.name == "END" && depth > 0:
+			depth--
+		case depth > 0:
+		case p.name == "END" && p.value == "VEVENT":
+			if skip == nil && rule != "" {
+				ev.rrule, skip = parseRRule(rule, ev.start.Location())
+			}
+			switch {
+			case skip != nil:
+				if flags.verbose {
+					fmt.Fprintf(os.Stderr, "Skipping event at line %d: %v\n", begin, skip)
+				}
+			case !ev.start.IsZero():
+				events = append(events, *ev)
+			}
+			ev = nil
+		case skip != nil:
+		case p.name == "RECURRENCE-ID":
+			// an override of a single occurrence, which we don't track
+			ev = nil
+		case p.name == "SUMMARY":
+			ev.summary = unescapeICS(p.value)
+		case p.name == "DTSTART":
+			ev.start, ev.allDay, skip = parseICSTime(p)
+		case p.name == "RRULE":
+			rule = p.value
+		case p.name == "EXDATE":
+			for _, v := range strings.Split(p.value, ",") {
+				var t time.Time
+				t, _, skip = parseICSTime(icsProperty{p.name, p.params, v})
+				if skip != nil {
+					break
+				}
+				ev.exdates[t.UTC()] = true
+			}
+		}
+	}
+	return events, nil
+}
+
+// unfoldICS splits the stream into content lines, joining continuation
+// lines that start with a space or tab
+func unfoldICS(r io.Reader) ([]string, error) {
+	var lines []string
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), "\r")
+		if len(line) > 0 && (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, sc.Err()
+}
+
+func parseICSLine(line string) (icsProperty, error) {
+	p := icsProperty{params: map[string]string{}}
+	// the value starts at the first colon that is not inside a quoted
+	// parameter value
+	quoted := false
+	colon := -1
+	for i := 0; i < len(line) && colon < 0; i++ {
+		switch line[i] {
+		case '"':
+			quoted = !quoted
+		case ':':
+			if !quoted {
+				colon = i
+			}
+		}
+	}
+	if colon < 0 {
+		return p, fmt.Errorf("malformed line %q", line)
+	}
+	p.value = line[colon+1:]
+	parts := strings.Split(line[:colon], ";")
+	p.name = strings.ToUpper(parts[0])
+	for _, param := range parts[1:] {
+		if i := strings.IndexByte(param, '='); i >= 0 {
+			p.params[strings.ToUpper(param[:i])] = strings.Trim(param[i+1:], `"`)
+		}
+	}
+	return p, nil
+}
+
+func unescapeICS(s string) string {
+	return strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`).Replace(s)
+}
+
+// parseICSTime parses a DATE or DATE-TIME value, honouring TZID. Floating
+// times and dates are taken to be local.
+func parseICSTime(p icsProperty) (time.Time, bool, error) {
+	loc := time.Local
+	if tzid, ok := p.params["TZID"]; ok {
+		var err error
+		loc, err = time.LoadLocation(tzid)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("unknown time zone %q", tzid)
+		}
+	}
+	if p.params["VALUE"] == "DATE" || len(p.value) == 8 {
+		t, err := time.ParseInLocation("20060102", p.value, loc)
+		return t, true, err
+	}
+	if strings.HasSuffix(p.value, "Z") {
+		t, err := time.Parse("20060102T150405Z", p.value)
+		return t, false, err
+	}
+	t, err := time.ParseInLocation("20060102T150405", p.value, loc)
+	if err != nil {
+		return t, false, err
+	}
+	return wallClock(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), loc), false, nil
+}
+
+var icsDays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+func parseRRule(s string, loc *time.Location) (*rrule, error) {
+	r := &rrule{interval: 1}
+	for _, part := range strings.Split(s, ";") {
+		i := strings.IndexByte(part, '=')
+		if i < 0 {
+			return nil, fmt.Errorf("malformed RRULE %q", s)
+		}
+		key, value := strings.ToUpper(part[:i]), part[i+1:]
+		var err error
+		switch key {
+		case "FREQ":
+			r.freq = strings.ToUpper(value)
+		case "INTERVAL":
+			r.interval, err = strconv.Atoi(value)
+			if err == nil && r.interval < 1 {
+				err = fmt.Errorf("invalid INTERVAL %q", value)
+			}
+		case "COUNT":
+			r.count, err = strconv.Atoi(value)
+		case "UNTIL":
+			r.until, _, err = parseICSTime(icsProperty{params: map[string]string{}, value: value})
+			if err == nil && !strings.HasSuffix(value, "Z") {
+				r.until = time.Date(r.until.Year(), r.until.Month(), r.until.Day(), r.until.Hour(), r.until.Minute(), r.until.Second(), 0, loc)
+			}
+		case "BYDAY":
+			for _, d := range strings.Split(value, ",") {
+				wd, ok := icsDays[strings.ToUpper(d)]
+				if !ok {
+					return nil, fmt.Errorf("unsupported BYDAY %q", value)
+				}
+				r.byday = append(r.byday, wd)
+			}
+		case "WKST":
+		default:
+			return nil, fmt.Errorf("unsupported RRULE part %q", key)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	switch r.freq {
+	case "DAILY", "WEEKLY", "MONTHLY", "YEARLY":
+	default:
+		return nil, fmt.Errorf("unsupported RRULE frequency %q", r.freq)
+	}
+	if len(r.byday) > 0 && r.freq != "WEEKLY" {
+		return nil, fmt.Errorf("BYDAY is only supported for weekly rules")
+	}
+	// BYDAY days are visited Monday first, the default week start
+	sort.Slice(r.byday, func(i, j int) bool { return (r.byday[i]+6)%7 < (r.byday[j]+6)%7 })
+	return r, nil
+}
+
+// maxOccurrences bounds recurrence expansion for rules that started long ago
+const maxOccurrences = 1000000
+
+// next returns the first occurrence of the event after t
+func (ev *vevent) next(t time.Time) (time.Time, bool) {
+	if ev.rrule == nil {
+		return ev.start, ev.start.After(t)
+	}
+	r := ev.rrule
+	loc := ev.start.Location()
+	y, m, d := ev.start.Date()
+	hh, mm, ss := ev.start.Clock()
+	n := 0
+	for period := 0; n < maxOccurrences; period++ {
+		var candidates []time.Time
+		switch r.freq {
+		case "DAILY":
+			candidates = append(candidates, wallClock(y, m, d+period*r.interval, hh, mm, ss, loc))
+		case "WEEKLY":
+			if len(r.byday) == 0 {
+				candidates = append(candidates, wallClock(y, m, d+7*period*r.interval, hh, mm, ss, loc))
+				break
+			}
+			// move to the Monday of the week containing the start
+			monday := d - (int(ev.start.Weekday())+6)%7 + 7*period*r.interval
+			for _, wd := range r.byday {
+				candidates = append(candidates, wallClock(y, m, monday+(int(wd)+6)%7, hh, mm, ss, loc))
+			}
+		case "MONTHLY":
+			first := time.Date(y, m+time.Month(period*r.interval), 1, 0, 0, 0, 0, loc)
+			// months without the start day are skipped, not clamped
+			if d <= daysIn(first.Year(), first.Month()) {
+				candidates = append(candidates, wallClock(first.Year(), first.Month(), d, hh, mm, ss, loc))
+			}
+		case "YEARLY":
+			year := y + period*r.interval
+			if d <= daysIn(year, m) {
+				candidates = append(candidates, wallClock(year, m, d, hh, mm, ss, loc))
+			}
+		}
+		for _, c := range candidates {
+			if c.Before(ev.start) {
+				continue
+			}
+			if !r.until.IsZero() && c.After(r.until) {
+				return time.Time{}, false
+			}
+			n++
+			if r.count > 0 && n > r.count {
+				return time.Time{}, false
+			}
+			if c.After(t) && !ev.exdates[c.UTC()] {
+				return c, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+func daysIn(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// parseNext handles `gutimer next`, which counts down to the next upcoming
+// event in an iCalendar file
+func parseNext(args []string) (Mode, time.Duration) {
+	fs := flag.NewFlagSet("next", flag.ExitOnError)
+	path := fs.String("ics", "", "iCalendar `file` to read events from")
+	fs.BoolVar(&flags.verbose, "v", false, "verbose")
+	displayFlags(fs)
+	fs.Parse(args)
+	if *path == "" || fs.NArg() != 0 {
+		fmt.Fprintln(os.Stderr, "Usage: gutimer next -ics file [display flags]")
+		os.Exit(1)
+	}
+	if err := checkDisplayFlags(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	f, err := os.Open(*path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to open calendar: %v\n", err)
+		os.Exit(1)
+	}
+	events, err := readICS(f)
+	f.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to read calendar: %v\n", err)
+		os.Exit(1)
+	}
+
+	now := time.Now()
+	var next time.Time
+	var summary string
+	for i := range events {
+		t, ok := events[i].next(now)
+		if ok && (next.IsZero() || t.Before(next)) {
+			next, summary = t, events[i].summary
+		}
+	}
+	if next.IsZero() {
+		fmt.Fprintln(os.Stderr, "No upcoming events")
+		os.Exit(1)
+	}
+
+	fmt.Fprintf(os.Stderr, "Next: %s at %s\n", summary, next.Local().Format("Mon Jan 2 15:04"))
+	flags.label = summary
+	flags.alarmAt = next
+	duration := next.Sub(now)
+	if duration >= day {
+		flags.seconds = true
+	}
+	return ALARM, duration
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// key is something read from the terminal: an ordinary key, one of the
+// keys that send an escape sequence, or text pasted in
+type key struct {
+	char byte   // an ordinary key, when name is empty
+	name string // up, down, left, right, home, end, f1 and so on, focus-in, focus-out, paste, click, wheel-up or wheel-down
+	text string // what was pasted
+	x, y int    // the column and row of the mouse or of a cursor position report, from 1
+}
+
+const (
+	bracketPaste   = "\x1b[?2004h" // have the terminal mark pasted text
+	unbracketPaste = "\x1b[?2004l"
+	pasteStart     = "\x1b[200~"
+	pasteEnd       = "\x1b[201~"
+)
+
+// csiKeys names the escape sequences that end in a letter, like ESC [ A
+// for up, with or without modifiers in front: ESC [ 1 ; 5 A is C-up
+var csiKeys = map[byte]string{
+	'A': "up", 'B': "down", 'C': "right", 'D': "left", 'H': "home", 'F': "end",
+	'P': "f1", 'Q': "f2", 'R': "f3", 'S': "f4", 'I': "focus-in", 'O': "focus-out",
+}
+
+// tildeKeys names the escape sequences that end in ~, by their number
+var tildeKeys = map[int]string{
+	1: "home", 2: "insert", 3: "delete", 4: "end", 5: "page-up", 6: "page-down",
+	7: "home", 8: "end", 11: "f1", 12: "f2", 13: "f3", 14: "f4", 15: "f5",
+	17: "f6", 18: "f7", 19: "f8", 20: "f9", 21: "f10", 23: "f11", 24: "f12",
+}
+
+// decoder turns what is read from the terminal into keys. A terminal sends
+// each escape sequence in one go, so an escape at the end of a read is the
+// escape key itself, but a paste can take many reads.
+type decoder struct {
+	pending []byte // the start of a sequence still to come in full
+	pasting bool
+	paste   []byte
+}
+
+func (d *decoder) decode(b []byte) []key {
+	buf := append(d.pending, b...)
+	d.pending = nil
+	var keys []key
+	for i := 0; i < len(buf); {
+		if d.pasting {
+			end := bytes.Index(buf[i:], []byte(pasteEnd))
+			if end < 0 {
+				// the end of the paste may have been cut in two
+				keep := partialSuffix(buf[i:], pasteEnd)
+				d.paste = append(d.paste, buf[i:len(buf)-keep]...)
+				d.pending = append([]byte(nil), buf[len(buf)-keep:]...)
+				return keys
+			}
+			d.paste = append(d.paste, buf[i:i+end]...)
+			keys = append(keys, key{name: "paste", text: string(d.paste)})
+			d.pasting, d.paste = false, nil
+			i += end + len(pasteEnd)
+			continue
+		}
+		if buf[i] != escape {
+			keys = append(keys, key{char: buf[i]})
+			i++
+			continue
+		}
+		k, n := sequence(buf[i:])
+		switch {
+		case n == 0:
+			// escape on its own, or in front of a key like meta does
+			keys = append(keys, key{char: escape})
+			i++
+		case n < 0:
+			d.pending = append([]byte(nil), buf[i:]...)
+			return keys
+		case k.name == "paste-start":
+			d.pasting = true
+			i += n
+		case k.name != "":
+			keys = append(keys, k)
+			i += n
+		default:
+			// not a key anything here knows
+			i += n
+		}
+	}
+	return keys
+}
+
+// sequence decodes the escape sequence at the start of b and returns its
+// length, 0 if the escape is not the start of one and -1 if it has not all
+// been read yet
+func sequence(b []byte) (key, int) {
+	if len(b) < 2 {
+		return key{}, 0
+	}
+	switch b[1] {
+	case 'O':
+		// SS3, sent for f1 to f4 and for arrows in application mode
+		if len(b) < 3 {
+			return key{}, -1
+		}
+		return key{name: csiKeys[b[2]]}, 3
+	case '[':
+		// CSI: parameters, then intermediates, then the final byte
+		i := 2
+		for i < len(b) && b[i] >= 0x30 && b[i] <= 0x3f {
+			i++
+		}
+		params := string(b[2:i])
+		for i < len(b) && b[i] >= 0x20 && b[i] <= 0x2f {
+			i++
+		}
+		if i == len(b) {
+			return key{}, -1
+		}
+		final := b[i]
+		if final < 0x40 || final > 0x7e {
+			return key{}, 0
+		}
+		if strings.HasPrefix(params, "<") && (final == 'M' || final == 'm') {
+			return mouseKey(params[1:], final == 'M'), i + 1
+		}
+		if final == 'R' {
+			// a cursor position report, ESC [ row ; column R, looks just
+			// like a modified f3, and is only taken for one while a mouse
+			// report waits for it
+			var k key
+			if _, err := fmt.Sscanf(params, "%d;%d", &k.y, &k.x); err != nil {
+				k = key{}
+			}
+			k.name = "f3"
+			return k, i + 1
+		}
+		if final != '~' {
+			return key{name: csiKeys[final]}, i + 1
+		}
+		n, _ := strconv.Atoi(strings.SplitN(params, ";", 2)[0])
+		switch n {
+		case 200:
+			return key{name: "paste-start"}, i + 1
+		case 201:
+			// the end of a paste that never started
+			return key{}, i + 1
+		}
+		return key{name: tildeKeys[n]}, i + 1
+	}
+	return key{}, 0
+}
+
+// mouseKey is the key for an SGR mouse report with params button ; column ;
+// row. Only presses of the left button and turns of the wheel are named.
+func mouseKey(params string, press bool) key {
+	var button int
+	var k key
+	if _, err := fmt.Sscanf(params, "%d;%d;%d", &button, &k.x, &k.y); err != nil || !press {
+		return key{}
+	}
+	switch button {
+	case 0:
+		k.name = "click"
+	case 64:
+		k.name = "wheel-up"
+	case 65:
+		k.name = "wheel-down"
+	default:
+		return key{}
+	}
+	return k
+}
+
+// partialSuffix is the length of the longest end of b that is the start of
+// marker
+func partialSuffix(b []byte, marker string) int {
+	for n := len(marker) - 1; n > 0; n-- {
+		if len(b) >= n && bytes.HasSuffix(b, []byte(marker[:n])) {
+			return n
+		}
+	}
+	return 0
+}
+
+// press acts on a key read from the terminal and reports whether to quit.
+// The arrow keys adjust a countdown, text pasted while the prompt is open
+// is typed into it and a duration pasted into a countdown starts it over,
+// the mouse works under -mouse, and anything else that is not an ordinary
+// key is left alone rather than being taken for one.
+func (t *timer) press(k key) bool {
+	switch {
+	case k.name == "":
+		return t.key(k.char)
+	case t.arrow(k.name):
+	case k.name == "click" || k.name == "wheel-up" || k.name == "wheel-down":
+		t.mouse(k)
+	case k.name == "f3" && k.y > 0 && t.mouseAt.name != "":
+		t.located(k.y)
+	case k.name == "paste" && t.prompt != "":
+		for _, r := range k.text {
+			if r >= ' ' && r != 0x7f {
+				t.input += string(r)
+			}
+		}
+		t.draw()
+	case k.name == "paste" && t.mode != STOPWATCH && !t.asking:
+		t.pasted(k.text)
+	}
+	return false
+}
+
+// pasted starts a countdown over with a duration pasted into it, like a
+// cook time copied from a recipe
+func (t *timer) pasted(text string) {
+	d, err := parseWordDuration(strings.TrimSpace(text))
+	if err != nil || d <= 0 {
+		screen.print(fmt.Sprintf("Pasted %.40q is not a duration\n", strings.TrimSpace(text)))
+		t.draw()
+		return
+	}
+	t.count = 0
+	t.reset(d)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// journalLimit is how many entries a journal grows to before it is
+// rotated down to the latest one
+const journalLimit = 1000
+
+// journal keeps state that has to survive a crash or a power cut. Every
+// update is appended as a line of JSON and synced to disk, and the state is
+// the last line that is whole, so an update cut off half way loses only
+// itself. Rotation writes the latest state to a new file and renames it
+// over the old one, which leaves either the old file or the new.
+type journal struct {
+	mu    sync.Mutex
+	path  string
+	f     *os.File
+	lines int
+	torn  bool // the last line was cut off
+}
+
+// openJournal opens the journal at path, creating it if needed, and returns
+// it with its latest entry, nil if it has none
+func openJournal(path string) (*journal, []byte, error) {
+	b, err := readLocked(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, nil, err
+	}
+	j := &journal{path: path, torn: len(b) > 0 && b[len(b)-1] != '\n'}
+	var last []byte
+	for _, line := range bytes.Split(b, []byte{'\n'}) {
+		if json.Valid(line) {
+			last = line
+			j.lines++
+		}
+	}
+	if err := j.reopen(); err != nil {
+		return nil, nil, err
+	}
+	return j, last, nil
+}
+
+func (j *journal) reopen() error {
+	f, err := os.OpenFile(j.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	j.f = f
+	return nil
+}
+
+// append adds v as the latest entry
+func (j *journal) append(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.lines >= journalLimit {
+		return j.rotate(b)
+	}
+	line := append(b, '\n')
+	// start a fresh line rather than writing on the end of one torn by a
+	// crash
+	if j.torn {
+		line = append([]byte{'\n'}, line...)
+	}
+	if err := lockFile(j.f, true); err != nil {
+		return err
+	}
+	err = syncLine(j.f, line)
+	unlockFile(j.f)
+	if err != nil {
+		return err
+	}
+	j.torn = false
+	j.lines++
+	return nil
+}
+
+// rotate replaces the journal with one holding only b
+func (j *journal) rotate(b []byte) error {
+	tmp := j.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if err := syncLine(f, append(b, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, j.path); err != nil {
+		return err
+	}
+	// the rename is only safe once the directory is on disk too
+	if d, err := os.Open(filepath.Dir(j.path)); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	j.f.Close()
+	j.lines = 1
+	j.torn = false
+	return j.reopen()
+}
+
+func (j *journal) close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.f.Close()
+}
+
+// readLocked reads the file at path under a shared lock, so not while
+// another instance is writing to it
+func readLocked(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if err := lockFile(f, false); err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(f)
+}
+
+// syncLine writes b and waits for it to reach the disk
+func syncLine(f *os.File, b []byte) error {
+	if _, err := f.Write(b); err != nil {
+		return err
+	}
+	return f.Sync()
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// lap is one lap of a stopwatch
+type lap struct {
+	at   time.Duration // elapsed time when it was taken
+	auto bool          // taken by -autosplit
+	note string
+	row  int // screen.printed once the lap was printed, for -mouse
+}
+
+// lap records a split of a stopwatch at elapsed time at and prints it above
+// the display with the time since the previous one. Automatic splits from
+// -autosplit are marked as such.
+func (t *timer) lap(at time.Duration, auto bool) {
+	prev := time.Duration(0)
+	if len(t.laps) > 0 {
+		prev = t.laps[len(t.laps)-1].at
+	}
+	t.laps = append(t.laps, lap{at: at, auto: auto})
+	kind := "lap"
+	note := ""
+	if auto {
+		kind = "split"
+		note = " (auto)"
+	}
+	t.rec.event(kind, at.String())
+	screen.print(fmt.Sprintf("Lap %d: %s +%s%s\n", len(t.laps), printDuration(at), printDuration(at-prev), note))
+	t.laps[len(t.laps)-1].row = screen.printed
+	t.draw()
+}
+
+// lapNow takes a lap at the time on the stopwatch right now
+func (t *timer) lapNow() {
+	at := t.elapsed
+	if !t.paused {
+		at = t.clock.Now().Sub(t.start)
+	}
+	t.lap(at, false)
+}
+
+// autosplit records the automatic splits that fell due up to the last tick
+func (t *timer) autosplit() {
+	if flags.autosplit <= 0 || t.mode != STOPWATCH {
+		return
+	}
+	for t.elapsed >= t.nextSplit {
+		t.lap(t.nextSplit, true)
+		t.nextSplit += flags.autosplit
+	}
+}
+
+// note attaches s to the last lap, printing it again with the note
+func (t *timer) note(s string) error {
+	if len(t.laps) == 0 {
+		return fmt.Errorf("no lap to add a note to")
+	}
+	t.noteLap(len(t.laps), s)
+	return nil
+}
+
+// noteLap attaches s to lap n, counted from 1, printing it again with the
+// note
+func (t *timer) noteLap(n int, s string) {
+	t.laps[n-1].note = strings.TrimSpace(s)
+	t.rec.event("note", t.laps[n-1].note)
+	screen.print(fmt.Sprintf("Lap %d: %s\n", n, t.laps[n-1].note))
+}
+
+// unlap takes back the last lap, for when l was pressed by mistake
+func (t *timer) unlap() error {
+	if len(t.laps) == 0 {
+		return fmt.Errorf("no lap to undo")
+	}
+	n := len(t.laps)
+	t.rec.event("unlap", t.laps[n-1].at.String())
+	t.laps = t.laps[:n-1]
+	screen.print(fmt.Sprintf("Lap %d undone\n", n))
+	return nil
+}
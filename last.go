@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// lastSize is how many stopwatch results gutimer last keeps
+const lastSize = 20
+
+// lastResult is a stopwatch result kept for gutimer last
+type lastResult struct {
+	End     time.Time `json:"end"`
+	Elapsed float64   `json:"elapsed"` // seconds
+	Label   string    `json:"label,omitempty"`
+}
+
+func lastFile() string {
+	return filepath.Join(dataDir(), "last.json")
+}
+
+// keepLast adds the result of a stopwatch to the ones gutimer last shows.
+// Unlike the history it is kept even with -history=false, and only the
+// most recent few are.
+func (t *timer) keepLast() {
+	if t.detached {
+		return
+	}
+	if t.mode != STOPWATCH {
+		return
+	}
+	err := updateLast(func(results []lastResult) []lastResult {
+		results = append(results, lastResult{End: time.Now(), Elapsed: t.elapsed.Seconds(), Label: flags.label})
+		if len(results) > lastSize {
+			results = results[len(results)-lastSize:]
+		}
+		return results
+	})
+	if err != nil {
+		screen.print(fmt.Sprintf("Unable to keep result: %v\n", err))
+	}
+}
+
+// updateLast replaces the kept results with what f makes of them. A lock
+// file keeps other instances out from reading to writing, and the new
+// results are renamed over the old so a crash leaves one or the other.
+func updateLast(f func([]lastResult) []lastResult) error {
+	if err := os.MkdirAll(dataDir(), 0755); err != nil {
+		return err
+	}
+	lock, err := os.OpenFile(lastFile()+".lock", os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+	if err := lockFile(lock, true); err != nil {
+		return err
+	}
+	results, err := readLast()
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(f(results))
+	if err != nil {
+		return err
+	}
+	tmp := lastFile() + ".tmp"
+	if err := ioutil.WriteFile(tmp, append(b, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, lastFile())
+}
+
+// readLast returns the kept results, oldest first
+func readLast() ([]lastResult, error) {
+	b, err := ioutil.ReadFile(lastFile())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var results []lastResult
+	if err := json.Unmarshal(b, &results); err != nil {
+		return nil, fmt.Errorf("%s: %v", lastFile(), err)
+	}
+	return results, nil
+}
+
+// last prints the most recent stopwatch results, the latest first
+func last(args []string) int {
+	fs := flag.NewFlagSet("last", flag.ExitOnError)
+	n := fs.Int("n", 5, "print `count` results")
+	displayFlags(fs)
+	fs.Parse(args)
+	if fs.NArg() != 0 || *n < 1 {
+		fmt.Fprintln(os.Stderr, "Usage: gutimer last [-n count] [display flags]")
+		return 1
+	}
+	if err := checkDisplayFlags(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	results, err := readLast()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to read results: %v\n", err)
+		return 1
+	}
+	if len(results) == 0 {
+		fmt.Fprintln(os.Stderr, "No stopwatch results yet")
+		return 1
+	}
+	for i := len(results) - 1; i >= 0 && i >= len(results)-*n; i-- {
+		r := results[i]
+		line := fmt.Sprintf("%s  %s", r.End.Local().Format("Mon Jan 2 15:04"), printDuration(time.Duration(r.Elapsed*float64(time.Second))))
+		if r.Label != "" {
+			line += "  " + r.Label
+		}
+		fmt.Println(line)
+	}
+	return 0
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// layout draws a block of lines and redraws it in place. Every frame moves
+// the cursor back up to the first line of the previous one, rewrites each
+// line and clears whatever is left of the old frame, so the block can grow
+// and shrink freely. The cursor is left at the end of the last line.
+//
+// The cursor is moved up rather than saved and restored, since a block at
+// the bottom of the screen scrolls the saved position away as it grows.
+// Lines longer than the terminal is wide take up more than one row, which
+// the move up has to count.
+type layout struct {
+	w       io.Writer
+	height  int      // rows in the last frame, 0 if nothing is on screen
+	plain   bool     // the terminal does not know escape codes, only \r
+	width   int      // visible width of the last frame when plain
+	last    []string // the lines of the last frame, for gutimer simulate
+	hidden  bool     // only print, not draw, as under -batch without a terminal
+	printed int      // rows printed above the block so far, for finding them by
+
+	// frames that took a while to write means the terminal is not keeping
+	// up, like over ssh on a slow link, and it gets less to write for a
+	// while: see slow
+	slowRun  int // slow writes in a row
+	quickRun int // quick writes in a row since the link went slow
+	backedUp bool
+}
+
+const (
+	slowWrite   = 50 * time.Millisecond // a frame write this long is backed up
+	slowFrames  = 3                     // slow writes in a row to slow down
+	quickWrite  = 5 * time.Millisecond  // a frame write this short is not
+	quickFrames = 60                    // quick writes in a row to speed up
+)
+
+// screen is where timers are drawn: stderr, leaving stdout for results.
+// TERM=dumb is what the likes of an Emacs shell buffer say they are.
+var screen = &layout{w: os.Stderr, plain: os.Getenv("TERM") == "dumb"}
+
+const (
+	clearLine = "\x1b[K" // erase to the end of the line
+	clearDown = "\x1b[J" // erase to the end of the screen
+)
+
+func (l *layout) draw(lines []string) {
+	l.last = lines
+	if l.hidden {
+		return
+	}
+	if l.plain {
+		l.drawPlain(strings.Join(lines, "  "))
+		return
+	}
+	var b strings.Builder
+	b.WriteString("\r")
+	if l.height > 1 {
+		b.WriteString(cursorUp(l.height - 1))
+	}
+	rows := 0
+	for i, line := range lines {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(line)
+		b.WriteString(clearLine)
+		rows += l.rows(line)
+	}
+	if rows < l.height {
+		b.WriteString(clearDown)
+	}
+	l.write(b.String())
+	l.height = rows
+}
+
+// drawPlain draws a frame on a terminal that only knows \r, by writing
+// over the last frame and blanking what is left of it with spaces
+func (l *layout) drawPlain(line string) {
+	width := visibleWidth(line)
+	pad := ""
+	if width < l.width {
+		pad = strings.Repeat(" ", l.width-width)
+	}
+	l.write("\r" + line + pad)
+	l.width = width
+	l.height = 1
+}
+
+// rows is how many rows of the terminal line takes up
+func (l *layout) rows(line string) int {
+	c := l.columns()
+	w := visibleWidth(line)
+	if c <= 0 || w <= c {
+		return 1
+	}
+	return (w + c - 1) / c
+}
+
+// visibleWidth is the number of cells s takes up, leaving out escape codes
+func visibleWidth(s string) int {
+	n := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\x1b' && i+1 < len(s) && s[i+1] == '[' {
+			// a CSI sequence runs to its final byte
+			for i += 2; i < len(s) && (s[i] < 0x40 || s[i] > 0x7e); i++ {
+			}
+			continue
+		}
+		if !utf8.RuneStart(s[i]) {
+			continue
+		}
+		n++
+	}
+	return n
+}
+
+// write writes a whole frame at once so it is never seen half drawn
+func (l *layout) write(frame string) {
+	started := time.Now()
+	io.WriteString(l.w, frame)
+	l.measure(time.Since(started))
+}
+
+// measure keeps track of how long frames take to write. A write blocks
+// when whatever is between gutimer and the terminal has all it can buffer.
+func (l *layout) measure(took time.Duration) {
+	switch {
+	case took >= slowWrite:
+		l.quickRun = 0
+		if l.slowRun++; l.slowRun >= slowFrames {
+			l.backedUp = true
+		}
+	case took <= quickWrite:
+		l.slowRun = 0
+		if l.backedUp {
+			if l.quickRun++; l.quickRun >= quickFrames {
+				l.backedUp, l.quickRun = false, 0
+			}
+		}
+	default:
+		l.slowRun = 0
+	}
+}
+
+// slow reports whether the terminal is not keeping up with the frames, so
+// they should come less often and without animation
+func (l *layout) slow() bool {
+	return l.backedUp
+}
+
+// bell rings the terminal bell
+func (l *layout) bell() {
+	if !l.hidden {
+		io.WriteString(l.w, "\a")
+	}
+}
+
+// finish leaves the last frame on screen and moves to a fresh line below it
+func (l *layout) finish() {
+	if l.height > 0 {
+		io.WriteString(l.w, "\n")
+	}
+	l.height, l.width = 0, 0
+}
+
+// print writes text above the block, which is drawn again on the next frame
+func (l *layout) print(s string) {
+	l.clear()
+	io.WriteString(l.w, s)
+	for _, line := range strings.SplitAfter(s, "\n") {
+		if strings.HasSuffix(line, "\n") {
+			l.printed += l.rows(strings.TrimSuffix(line, "\n"))
+		}
+	}
+}
+
+// clear erases the block and leaves the cursor where it started
+func (l *layout) clear() {
+	if l.height == 0 {
+		return
+	}
+	if l.plain {
+		io.WriteString(l.w, "\r"+strings.Repeat(" ", l.width)+"\r")
+		l.height, l.width = 0, 0
+		return
+	}
+	up := ""
+	if l.height > 1 {
+		up = cursorUp(l.height - 1)
+	}
+	io.WriteString(l.w, "\r"+up+clearDown)
+	l.height = 0
+}
+
+func cursorUp(n int) string {
+	return "\x1b[" + strconv.Itoa(n) + "A"
+}
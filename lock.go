@@ -0,0 +1,54 @@
+package main
+
+import "sync/atomic"
+
+const (
+	lockKey    = '\x0b' // C-k
+	unlockWord = "unlock"
+)
+
+// keyLock keeps a timer from being stopped by a stray key press. While it is
+// locked q, C-d and C-c do nothing and every key is swallowed; typing
+// "unlock" is the only way out. C-k locks it again.
+type keyLock struct {
+	locked int32 // read by the display, so accessed atomically
+	typed  int   // how much of unlockWord has been typed
+}
+
+var keys keyLock
+
+func (k *keyLock) isLocked() bool {
+	return atomic.LoadInt32(&k.locked) != 0
+}
+
+func (k *keyLock) lock() {
+	atomic.StoreInt32(&k.locked, 1)
+	k.typed = 0
+}
+
+func (k *keyLock) unlock() {
+	atomic.StoreInt32(&k.locked, 0)
+}
+
+// pass reports whether the key b read from the terminal should be acted on
+func (k *keyLock) pass(b byte) bool {
+	if !k.isLocked() {
+		if b == lockKey {
+			k.lock()
+			return false
+		}
+		return true
+	}
+	switch {
+	case b == unlockWord[k.typed]:
+		k.typed++
+	case b == unlockWord[0]:
+		k.typed = 1
+	default:
+		k.typed = 0
+	}
+	if k.typed == len(unlockWord) {
+		k.unlock()
+	}
+	return false
+}
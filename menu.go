@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// menuRecent is how many recent timers the menu offers
+const menuRecent = 5
+
+// menuItem is something the menu can start: a preset by name, or a
+// recent timer by the command line it was started with
+type menuItem struct {
+	name string
+	what string
+	args []string
+}
+
+// historyArgs is a command line that starts a timer like the one in an
+// entry from before the history kept command lines, false for the modes
+// that can't be started from it alone
+func historyArgs(entry historyEntry) ([]string, bool) {
+	var args []string
+	duration := time.Duration(entry.Duration * float64(time.Second)).Round(time.Second)
+	switch entry.Mode {
+	case "countdown":
+		args = []string{"-c", duration.String()}
+	case "timer":
+		args = []string{"-t", duration.String()}
+	case "stopwatch":
+		args = []string{"-s"}
+	default:
+		return nil, false
+	}
+	if entry.Label != "" {
+		args = append([]string{"-label", entry.Label}, args...)
+	}
+	return args, true
+}
+
+// menuItems are the presets, by name, then the command lines of the most
+// recent timers in the history that are not the same as one before them
+func menuItems() ([]menuItem, error) {
+	presets, err := readPresets()
+	if err != nil {
+		return nil, err
+	}
+	var items []menuItem
+	for name, value := range presets {
+		items = append(items, menuItem{name: name, what: value, args: []string{name}})
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].name < items[j].name })
+
+	entries, err := readHistory()
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	for i := len(entries) - 1; i >= 0 && len(seen) < menuRecent; i-- {
+		args, ok := entries[i].Args, len(entries[i].Args) > 0
+		if !ok {
+			args, ok = historyArgs(entries[i])
+		}
+		if !ok || seen[showArgs(args)] {
+			continue
+		}
+		seen[showArgs(args)] = true
+		items = append(items, menuItem{name: "recent", what: showArgs(args), args: args})
+	}
+	return items, nil
+}
+
+// drawMenu draws the items with the one picked marked
+func drawMenu(items []menuItem, picked int) {
+	width := 0
+	for _, item := range items {
+		if len(item.name) > width {
+			width = len(item.name)
+		}
+	}
+	lines := []string{"Start which? up and down to pick, enter to start, q to quit"}
+	for i, item := range items {
+		line := fmt.Sprintf("%-*s  %s", width, item.name, item.what)
+		switch {
+		case i != picked:
+			line = "  " + line
+		case screen.plain:
+			line = "> " + line
+		default:
+			line = "> \x1b[7m" + line + "\x1b[0m"
+		}
+		lines = append(lines, line)
+	}
+	screen.draw(lines)
+}
+
+// menu offers the presets and recent timers to start when gutimer is run
+// without arguments, and returns the command line of the one picked, none
+// if the user quit. It reports false if there was nothing to offer.
+func menu() ([]string, bool, error) {
+	items, err := menuItems()
+	if err != nil || len(items) == 0 {
+		return nil, false, err
+	}
+	picked := 0
+	chosen := false
+	drawMenu(items, picked)
+	err = askKeys(func(k key) bool {
+		switch {
+		case k.name == "up" || k.name == "" && k.char == 'k':
+			picked = (picked + len(items) - 1) % len(items)
+		case k.name == "down" || k.name == "" && k.char == 'j':
+			picked = (picked + 1) % len(items)
+		case k.name == "home":
+			picked = 0
+		case k.name == "end":
+			picked = len(items) - 1
+		case k.name == "" && (k.char == '\r' || k.char == '\n'):
+			chosen = true
+			return true
+		}
+		drawMenu(items, picked)
+		return false
+	})
+	if err != nil || !chosen {
+		return nil, true, err
+	}
+	return items[picked].args, true, nil
+}
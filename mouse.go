@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// the terminal reports the mouse as ESC [ < button ; column ; row M once
+// asked to, which the decoder reads as click, wheel-up and wheel-down
+const (
+	reportMouse   = "\x1b[?1000h\x1b[?1006h"
+	unreportMouse = "\x1b[?1006l\x1b[?1000l"
+	askPosition   = "\x1b[6n"
+)
+
+// mouse acts on a click or a turn of the wheel under -mouse. The display is
+// drawn wherever the cursor was, so to tell what is under the mouse the
+// terminal is asked where the cursor is, and located does the rest once it
+// says.
+func (t *timer) mouse(k key) {
+	if t.prompt != "" || t.asking {
+		return
+	}
+	t.mouseAt = k
+	io.WriteString(screen.w, askPosition)
+}
+
+// located acts on the mouse report waiting in mouseAt, now that the cursor,
+// at the end of the last line of the display, is known to be on row. A
+// click on the display is space, the wheel over it adds or takes away a
+// minute like + and -, and a click on a lap above it asks for a note.
+func (t *timer) located(row int) {
+	k := t.mouseAt
+	t.mouseAt = key{}
+	top := row - screen.height + 1
+	switch {
+	case k.y >= top && k.y <= row:
+		switch {
+		case k.name == "click":
+			t.key(' ')
+		case t.mode == STOPWATCH || t.waiting:
+		case k.name == "wheel-up":
+			t.adjust(adjustUnit)
+		case k.name == "wheel-down":
+			t.adjust(-adjustUnit)
+		}
+	case k.name == "click" && k.y < top:
+		// laps are found by how many rows were printed after them
+		above := top - k.y
+		for i := range t.laps {
+			if screen.printed-t.laps[i].row+1 == above {
+				n := i + 1
+				t.ask(fmt.Sprintf("Note for lap %d: ", n), func(s string) (bool, error) {
+					if s != "" {
+						t.noteLap(n, s)
+					}
+					return false, nil
+				})
+			}
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// namedStopwatch is a stopwatch kept across runs with -name: quitting saves
+// the time on it and the next run with the same name carries on from there
+type namedStopwatch struct {
+	name    string
+	lock    *os.File // held for as long as the stopwatch runs
+	journal *journal
+	savedState
+}
+
+// savedState is what the state file of a named stopwatch holds
+type savedState struct {
+	Elapsed float64   `json:"elapsed"` // seconds
+	Updated time.Time `json:"updated"`
+}
+
+func stopwatchDir() string {
+	return filepath.Join(dataDir(), "stopwatches")
+}
+
+// checkName makes sure a stopwatch name can be used as a file name
+func checkName(name string) error {
+	if name == "" || name == "." || name == ".." || filepath.Base(name) != name {
+		return fmt.Errorf("invalid stopwatch name %q", name)
+	}
+	return nil
+}
+
+// openStopwatch locks the named stopwatch and reads the time saved on it,
+// none for a new one. Only one run at a time can have it open.
+func openStopwatch(name string) (*namedStopwatch, error) {
+	if err := checkName(name); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(stopwatchDir(), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(stopwatchDir(), name+".lock"), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if ok, err := tryLockFile(f); !ok {
+		f.Close()
+		if err == nil {
+			err = fmt.Errorf("stopwatch %s is already running", name)
+		}
+		return nil, err
+	}
+	sw := &namedStopwatch{name: name, lock: f}
+	j, last, err := openJournal(filepath.Join(stopwatchDir(), name+".journal"))
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	sw.journal = j
+	if last != nil {
+		if err := json.Unmarshal(last, &sw.savedState); err != nil {
+			sw.close()
+			return nil, fmt.Errorf("%s: %v", j.path, err)
+		}
+	}
+	return sw, nil
+}
+
+// elapsed is the time saved on the stopwatch
+func (sw *namedStopwatch) elapsed() time.Duration {
+	return time.Duration(sw.Elapsed * float64(time.Second))
+}
+
+// save records the time on the stopwatch
+func (sw *namedStopwatch) save(elapsed time.Duration) error {
+	return sw.journal.append(savedState{Elapsed: elapsed.Seconds(), Updated: time.Now()})
+}
+
+// checkpointInterval is how often a running named stopwatch saves its
+// time, which is all a crash can lose
+const checkpointInterval = 30 * time.Second
+
+// checkpoint saves the time of t every checkpointInterval until ctx is
+// cancelled
+func (sw *namedStopwatch) checkpoint(ctx context.Context, t *timer) {
+	tk := time.NewTicker(checkpointInterval)
+	defer tk.Stop()
+	for {
+		select {
+		case <-tk.C:
+			st, ok := t.query(ctx)
+			if !ok {
+				return
+			}
+			// a failure shows up when the last save on exit fails too
+			sw.save(st.Elapsed)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// close unlocks the stopwatch for the next run
+func (sw *namedStopwatch) close() {
+	sw.journal.close()
+	sw.lock.Close()
+}
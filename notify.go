@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// alert tells the user a countdown has ended: the bell, and a desktop
+// notification, speech and a sound when asked for. -media pauses or plays music
+// then too, for a sleep timer.
+func (t *timer) alert() {
+	screen.bell()
+	t.rec.event("bell", "")
+	msg := "Time is up"
+	if flags.label != "" {
+		msg = flags.label + ": time is up"
+	}
+	if flags.notify {
+		start(notifyCommand("gutimer", msg))
+	}
+	if flags.speak {
+		start(speakCommand(msg))
+	}
+	playSound(flags.sound)
+	if flags.media != "" {
+		start(mediaCommand(flags.media))
+	}
+}
+
+// finishAction carries out -on-finish once a countdown has run to its end
+func finishAction() {
+	args := finishCommands[flags.onFinish]
+	if out, err := exec.Command(args[0], args[1:]...).CombinedOutput(); err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to %s: %v %s\n", flags.onFinish, err, out)
+	}
+}
+
+// start runs a helper command in the background, without waiting for it or
+// caring whether it works beyond saying so on -v
+func start(cmd *exec.Cmd) {
+	if cmd == nil {
+		return
+	}
+	if err := cmd.Start(); err != nil {
+		if flags.verbose {
+			screen.print(fmt.Sprintf("Unable to run %s: %v\n", cmd.Path, err))
+		}
+		return
+	}
+	go cmd.Wait()
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"os/exec"
+	"strconv"
+)
+
+// notifyCommand shows a notification through Notification Center
+func notifyCommand(title, msg string) *exec.Cmd {
+	script := "display notification " + strconv.Quote(msg) + " with title " + strconv.Quote(title) + ` sound name "default"`
+	return exec.Command("osascript", "-e", script)
+}
+
+// speakCommand reads msg out loud
+func speakCommand(msg string) *exec.Cmd {
+	return exec.Command("say", msg)
+}
+
+// mediaCommand pauses or plays Music
+func mediaCommand(action string) *exec.Cmd {
+	return exec.Command("osascript", "-e", `tell application "Music" to `+action)
+}
+
+// finishCommands carry out -on-finish
+var finishCommands = map[string][]string{
+	"suspend":  {"pmset", "sleepnow"},
+	"shutdown": {"osascript", "-e", `tell application "System Events" to shut down`},
+	"lock":     {"pmset", "displaysleepnow"},
+}
+
+// soundCommand plays a sound file
+func soundCommand(path string) *exec.Cmd {
+	return exec.Command("afplay", path)
+}
@@ -0,0 +1,51 @@
+package main
+
+import "os/exec"
+
+// notifyCommand shows a desktop notification through libnotify
+func notifyCommand(title, msg string) *exec.Cmd {
+	return exec.Command("notify-send", "-a", "gutimer", title, msg)
+}
+
+// speakCommand reads msg out loud with speech-dispatcher, or espeak if that
+// is all there is
+func speakCommand(msg string) *exec.Cmd {
+	if _, err := exec.LookPath("spd-say"); err == nil {
+		return exec.Command("spd-say", msg)
+	}
+	return exec.Command("espeak", msg)
+}
+
+// mprisScript sends $1, Play or Pause, to every MPRIS player on the session
+// bus, for when playerctl isn't installed
+const mprisScript = `for p in $(dbus-send --session --print-reply --dest=org.freedesktop.DBus /org/freedesktop/DBus org.freedesktop.DBus.ListNames | grep -o 'org\.mpris\.MediaPlayer2\.[^"]*'); do
+	dbus-send --session --type=method_call --dest="$p" /org/mpris/MediaPlayer2 "org.mpris.MediaPlayer2.Player.$1"
+done`
+
+// mediaCommand pauses or plays the media players through MPRIS
+func mediaCommand(action string) *exec.Cmd {
+	if _, err := exec.LookPath("playerctl"); err == nil {
+		return exec.Command("playerctl", "--all-players", action)
+	}
+	method := "Pause"
+	if action == "play" {
+		method = "Play"
+	}
+	return exec.Command("sh", "-c", mprisScript, "sh", method)
+}
+
+// finishCommands carry out -on-finish through systemd
+var finishCommands = map[string][]string{
+	"suspend":  {"systemctl", "suspend"},
+	"shutdown": {"systemctl", "poweroff"},
+	"lock":     {"loginctl", "lock-session"},
+}
+
+// soundCommand plays a sound file through PulseAudio or PipeWire, or ALSA if
+// that is all there is
+func soundCommand(path string) *exec.Cmd {
+	if _, err := exec.LookPath("paplay"); err == nil {
+		return exec.Command("paplay", path)
+	}
+	return exec.Command("aplay", "-q", path)
+}
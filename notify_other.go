@@ -0,0 +1,30 @@
+//go:build !darwin && !linux
+// +build !darwin,!linux
+
+package main
+
+import "os/exec"
+
+// notifyCommand has nothing to show notifications with here
+func notifyCommand(title, msg string) *exec.Cmd {
+	return nil
+}
+
+// speakCommand has nothing to speak with here
+func speakCommand(msg string) *exec.Cmd {
+	return nil
+}
+
+// mediaCommand has no media players to control here
+func mediaCommand(action string) *exec.Cmd {
+	return nil
+}
+
+// finishCommands is empty, there being no known way to suspend or shut
+// down here
+var finishCommands = map[string][]string{}
+
+// soundCommand has nothing to play sounds with here
+func soundCommand(path string) *exec.Cmd {
+	return nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// resultFormats are the units -print can write the time a timer ran for in
+var resultFormats = map[string]func(time.Duration) string{
+	"seconds": func(d time.Duration) string { return strconv.FormatInt(int64(d/time.Second), 10) },
+	"ms":      func(d time.Duration) string { return strconv.FormatInt(int64(d/time.Millisecond), 10) },
+	"go":      time.Duration.String,
+}
+
+// printResult writes the time a timer ran for on stdout: in the unit of
+// -print, or as it was displayed when stdout is not the terminal the timer
+// was drawn on, so DURATION=$(gutimer -s) works. With -events - stdout is
+// only JSON lines, whose ticks have the time instead.
+func printResult(d time.Duration) {
+	if flags.events == "-" {
+		return
+	}
+	if f := resultFormats[flags.print]; f != nil {
+		fmt.Println(f(d))
+		return
+	}
+	if term.IsTerminal(int(os.Stdout.Fd())) {
+		return
+	}
+	fmt.Println(strings.Trim(printDuration(d), "[]"))
+}
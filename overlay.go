@@ -0,0 +1,55 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	altScreen  = "\x1b[?1049h\x1b[H" // switch to the alternate screen, cursor home
+	mainScreen = "\x1b[?1049l"       // and back, as it was before
+)
+
+// alternate switches between the normal screen and the alternate one, which
+// covers the whole terminal and goes away without a trace
+func (l *layout) alternate(on bool) {
+	if on {
+		io.WriteString(l.w, altScreen)
+	} else {
+		io.WriteString(l.w, mainScreen)
+	}
+	l.height = 0
+}
+
+// overlayFrame fills the terminal with lines centered in it, by the width
+// they take up, leaving out escape codes like colors
+func overlayFrame(lines []string) []string {
+	cols, rows := 80, 24
+	if f, ok := screen.w.(interface{ Fd() uintptr }); ok {
+		if c, r := terminalSize(f.Fd()); c > 0 && r > 0 {
+			cols, rows = c, r
+		}
+	}
+	if len(lines) > rows {
+		lines = lines[:rows]
+	}
+	out := make([]string, rows)
+	top := (rows - len(lines)) / 2
+	for i, line := range lines {
+		if pad := (cols - visibleWidth(line)) / 2; pad > 0 {
+			line = strings.Repeat(" ", pad) + line
+		}
+		out[top+i] = line
+	}
+	return out
+}
+
+// breakFrame is what -break-screen shows during a break
+func breakFrame(mode Mode, total, duration time.Duration) []string {
+	return overlayFrame([]string{
+		"Take a break — " + compactLine(mode, total, duration) + " remaining",
+		"",
+		"keys are locked, type \"" + unlockWord + "\" to use them",
+	})
+}
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	day  = 24 * time.Hour
+	week = 7 * day
+)
+
+var units = map[string]time.Duration{
+	"ns": time.Nanosecond,
+	"us": time.Microsecond,
+	"µs": time.Microsecond, // U+00B5 micro sign
+	"μs": time.Microsecond, // U+03BC greek mu
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+	"d":  day,
+	"w":  week,
+}
+
+// parseDuration parses a duration argument. This is either a single
+// duration or a simple expression combining them with +, -, * and /, like
+// 25m+5m, 1h-10m or 3*(20m+5m).
+func parseDuration(s string) (time.Duration, error) {
+	p := &exprParser{src: s, orig: s}
+	v, err := p.expr()
+	if err != nil {
+		return 0, err
+	}
+	p.skipSpace()
+	if p.src != "" {
+		return 0, fmt.Errorf("unexpected %q in duration %q", p.src, s)
+	}
+	if v.number {
+		// a bare 0 needs no unit
+		if v.n != 0 {
+			return 0, fmt.Errorf("missing unit in duration %q", s)
+		}
+		return 0, nil
+	}
+	if v.d < 0 {
+		return 0, fmt.Errorf("duration %q is negative", s)
+	}
+	return v.d, nil
+}
+
+// unitWords are the units of durations as people write them out
+var unitWords = map[string]string{
+	"sec": "s", "secs": "s", "second": "s", "seconds": "s",
+	"min": "m", "mins": "m", "minute": "m", "minutes": "m",
+	"hr": "h", "hrs": "h", "hour": "h", "hours": "h",
+}
+
+// parseWordDuration parses a duration the way parseDuration does, or as it
+// might be written in a recipe: 25 minutes, 1 hour and 10 mins, 90 sec
+func parseWordDuration(s string) (time.Duration, error) {
+	if d, err := parseDuration(s); err == nil {
+		return d, nil
+	}
+	var b strings.Builder
+	for _, w := range strings.Fields(strings.ToLower(s)) {
+		w = strings.TrimRight(w, ",;.")
+		if w == "and" {
+			continue
+		}
+		i := strings.IndexFunc(w, func(r rune) bool { return r != '.' && (r < '0' || r > '9') })
+		if i < 0 {
+			b.WriteString(w)
+			continue
+		}
+		unit, ok := unitWords[w[i:]]
+		if !ok {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		b.WriteString(w[:i] + unit)
+	}
+	return parseDuration(b.String())
+}
+
+// value is an operand in a duration expression, either a plain number or a
+// duration
+type value struct {
+	number bool
+	n      float64
+	d      time.Duration
+}
+
+type exprParser struct {
+	src  string
+	orig string
+}
+
+func (p *exprParser) skipSpace() {
+	p.src = strings.TrimLeft(p.src, " \t")
+}
+
+// peek returns the next operator or parenthesis without consuming it, or 0
+func (p *exprParser) peek() byte {
+	p.skipSpace()
+	if p.src != "" && strings.IndexByte("+-*/()", p.src[0]) >= 0 {
+		return p.src[0]
+	}
+	return 0
+}
+
+// expr = term { ("+" | "-") term }
+func (p *exprParser) expr() (value, error) {
+	v, err := p.term()
+	if err != nil {
+		return v, err
+	}
+	for op := p.peek(); op == '+' || op == '-'; op = p.peek() {
+		p.src = p.src[1:]
+		r, err := p.term()
+		if err != nil {
+			return v, err
+		}
+		if v.number != r.number {
+			return v, fmt.Errorf("cannot mix numbers and durations with %q in %q", op, p.orig)
+		}
+		if op == '-' {
+			r.n, r.d = -r.n, -r.d
+		}
+		if !v.number && (r.d > 0 && v.d > maxDuration-r.d || r.d < 0 && v.d < -maxDuration-r.d) {
+			return v, fmt.Errorf("duration %q is too long", p.orig)
+		}
+		v.n += r.n
+		v.d += r.d
+	}
+	return v, nil
+}
+
+// term = factor { ("*" | "/") factor }
+func (p *exprParser) term() (value, error) {
+	v, err := p.factor()
+	if err != nil {
+		return v, err
+	}
+	for op := p.peek(); op == '*' || op == '/'; op = p.peek() {
+		p.src = p.src[1:]
+		r, err := p.factor()
+		if err != nil {
+			return v, err
+		}
+		if op == '/' && !r.number {
+			return v, fmt.Errorf("cannot divide by a duration in %q", p.orig)
+		}
+		if !v.number && !r.number {
+			return v, fmt.Errorf("cannot multiply durations in %q", p.orig)
+		}
+		if op == '/' && r.n == 0 {
+			return v, fmt.Errorf("division by zero in %q", p.orig)
+		}
+		if v.number && !r.number {
+			v, r = r, v
+		}
+		if op == '/' {
+			r.n = 1 / r.n
+		}
+		if v.number {
+			v.n *= r.n
+			continue
+		}
+		f := float64(v.d) * r.n
+		if f >= float64(maxDuration) || f <= -float64(maxDuration) {
+			return v, fmt.Errorf("duration %q is too long", p.orig)
+		}
+		v.d = time.Duration(f)
+	}
+	return v, nil
+}
+
+// factor = number | duration | "(" expr ")"
+func (p *exprParser) factor() (value, error) {
+	switch p.peek() {
+	case '(':
+		p.src = p.src[1:]
+		v, err := p.expr()
+		if err != nil {
+			return v, err
+		}
+		if p.peek() != ')' {
+			return v, fmt.Errorf("missing ) in duration %q", p.orig)
+		}
+		p.src = p.src[1:]
+		return v, nil
+	case 0:
+	default:
+		return value{}, fmt.Errorf("unexpected %q in duration %q", p.src[0], p.orig)
+	}
+
+	i := strings.IndexAny(p.src, "+-*/() \t")
+	if i < 0 {
+		i = len(p.src)
+	}
+	tok := p.src[:i]
+	p.src = p.src[i:]
+	if tok == "" {
+		return value{}, fmt.Errorf("missing operand in duration %q", p.orig)
+	}
+	if strings.Trim(tok, ".0123456789") == "" {
+		n, err := strconv.ParseFloat(tok, 64)
+		if err != nil {
+			return value{}, fmt.Errorf("invalid number %q in duration %q", tok, p.orig)
+		}
+		return value{number: true, n: n}, nil
+	}
+	d, err := parseDurationLiteral(tok)
+	return value{d: d}, err
+}
+
+// parseDurationLiteral parses a single duration. It accepts the same syntax
+// as time.ParseDuration, without a sign, plus days (d) and weeks (w), as well
+// as ISO 8601 durations like PT1H30M.
+func parseDurationLiteral(s string) (time.Duration, error) {
+	if strings.HasPrefix(s, "P") {
+		return parseISODuration(s)
+	}
+	orig := s
+	if s == "" {
+		return 0, fmt.Errorf("invalid duration %q", orig)
+	}
+	if s == "0" {
+		return 0, nil
+	}
+
+	var d time.Duration
+	for s != "" {
+		i := strings.IndexFunc(s, func(r rune) bool { return r != '.' && (r < '0' || r > '9') })
+		if i == 0 {
+			return 0, fmt.Errorf("invalid duration %q", orig)
+		}
+		if i < 0 {
+			return 0, fmt.Errorf("missing unit in duration %q", orig)
+		}
+		num := s[:i]
+		s = s[i:]
+		j := strings.IndexAny(s, ".0123456789")
+		if j < 0 {
+			j = len(s)
+		}
+		unit, ok := units[s[:j]]
+		if !ok {
+			return 0, fmt.Errorf("unknown unit %q in duration %q", s[:j], orig)
+		}
+		s = s[j:]
+
+		part, err := scaleNumber(num, unit)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", orig)
+		}
+		if d > maxDuration-part {
+			return 0, fmt.Errorf("duration %q is too long", orig)
+		}
+		d += part
+	}
+	return d, nil
+}
+
+const maxDuration time.Duration = 1<<63 - 1
+
+// scaleNumber multiplies a decimal number by unit, keeping the whole part
+// exact and only using floating point for the fraction
+func scaleNumber(num string, unit time.Duration) (time.Duration, error) {
+	whole, frac := num, ""
+	if i := strings.IndexByte(num, '.'); i >= 0 {
+		whole, frac = num[:i], num[i+1:]
+	}
+	if whole == "" && frac == "" {
+		return 0, fmt.Errorf("invalid number %q", num)
+	}
+	var d time.Duration
+	if whole != "" {
+		w, err := strconv.ParseInt(whole, 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		if w > int64(maxDuration/unit) {
+			return 0, fmt.Errorf("number %q out of range", num)
+		}
+		d = time.Duration(w) * unit
+	}
+	if frac != "" {
+		f, err := strconv.ParseFloat("0."+frac, 64)
+		if err != nil {
+			return 0, err
+		}
+		d += time.Duration(f * float64(unit))
+	}
+	return d, nil
+}
+
+// parseISODuration parses an ISO 8601 duration such as P1DT2H or PT1H30M.
+// Years and months are rejected since their length depends on the date they
+// are counted from.
+func parseISODuration(s string) (time.Duration, error) {
+	orig := s
+	if len(s) < 2 || s[0] != 'P' {
+		return 0, fmt.Errorf("invalid ISO 8601 duration %q", orig)
+	}
+	s = s[1:]
+
+	var d time.Duration
+	inTime := false
+	for s != "" {
+		if s[0] == 'T' {
+			if inTime || len(s) == 1 {
+				return 0, fmt.Errorf("invalid ISO 8601 duration %q", orig)
+			}
+			inTime = true
+			s = s[1:]
+			continue
+		}
+		i := strings.IndexFunc(s, func(r rune) bool { return r != '.' && r != ',' && (r < '0' || r > '9') })
+		if i <= 0 {
+			return 0, fmt.Errorf("invalid ISO 8601 duration %q", orig)
+		}
+		// ISO 8601 allows a comma as the decimal separator
+		num := strings.Replace(s[:i], ",", ".", 1)
+		designator := s[i]
+		s = s[i+1:]
+
+		var unit time.Duration
+		switch {
+		case !inTime && designator == 'W':
+			unit = week
+		case !inTime && designator == 'D':
+			unit = day
+		case inTime && designator == 'H':
+			unit = time.Hour
+		case inTime && designator == 'M':
+			unit = time.Minute
+		case inTime && designator == 'S':
+			unit = time.Second
+		case !inTime && (designator == 'Y' || designator == 'M'):
+			return 0, fmt.Errorf("years and months are not supported in duration %q", orig)
+		default:
+			return 0, fmt.Errorf("unknown designator %q in duration %q", designator, orig)
+		}
+
+		part, err := scaleNumber(num, unit)
+		if err != nil {
+			return 0, fmt.Errorf("invalid ISO 8601 duration %q", orig)
+		}
+		if d > maxDuration-part {
+			return 0, fmt.Errorf("duration %q is too long", orig)
+		}
+		d += part
+	}
+	return d, nil
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// phase is one timer in a run made of several timers in a row
+type phase struct {
+	mode     Mode
+	duration time.Duration
+	label    string        // shown in front of the time instead of -label
+	announce string        // printed as the phase begins
+	kind     string        // what the history calls the phase
+	status   func() string // a line to show under the timer, worked out as the phase begins
+	overlay  bool          // fill the screen and lock the keys while it runs
+
+	// set by routines: commands to run as it starts and ends, a sound to
+	// play at the end and the color of the time
+	onStart, onEnd string
+	sound          string
+	color          string
+}
+
+// racePhases counts down prep and then starts a stopwatch, like the start
+// of a race
+func racePhases(prep time.Duration) []phase {
+	return []phase{
+		{mode: COUNTDOWN, duration: prep, label: "Ready"},
+		{mode: STOPWATCH, announce: "Go!"},
+	}
+}
+
+// name is how a phase is listed in the overview
+func (p phase) name() string {
+	if p.label != "" {
+		return p.label
+	}
+	return strings.Title(p.mode.String())
+}
+
+// overviewBefore and overviewAfter are how many phases around the current
+// one the overview lists
+const (
+	overviewBefore = 1
+	overviewAfter  = 4
+)
+
+// overview lists the phases around the current one with their lengths, the
+// current one highlighted
+func overview(phases []phase, current int) []string {
+	if len(phases) < 2 {
+		return nil
+	}
+	from, to := current-overviewBefore, current+overviewAfter+1
+	if from < 0 {
+		from = 0
+	}
+	if to > len(phases) {
+		to = len(phases)
+	}
+	var lines []string
+	for i := from; i < to; i++ {
+		p := phases[i]
+		length := "open"
+		if p.mode != STOPWATCH {
+			length = printDuration(p.duration)
+		}
+		line := fmt.Sprintf("  %d. %-16s %s", i+1, p.name(), length)
+		if i == current {
+			line = color(highlight, ">"+line[1:])
+		}
+		lines = append(lines, line)
+	}
+	if to < len(phases) {
+		lines = append(lines, fmt.Sprintf("     ... %d more", len(phases)-to))
+	}
+	return lines
+}
+
+// delayPhase is the short countdown -after puts in front of a timer
+func delayPhase(d time.Duration) phase {
+	return phase{mode: COUNTDOWN, duration: d, label: "Starting in"}
+}
+
+// runPhases runs each phase in turn, each starting as soon as the one before
+// it ends. n skips to the next phase and b goes back to the start of the
+// previous one. Quitting during any phase ends the whole run, which is
+// reported along with the exit code.
+func runPhases(ctx context.Context, clock Clock, phases []phase, rec *recorder, c chan key, e chan int) (int, bool) {
+	label := flags.label
+	defer func() { flags.label = label }()
+	for i := 0; i < len(phases); i++ {
+		p := phases[i]
+		if p.announce != "" {
+			fmt.Fprintln(os.Stderr, p.announce)
+		}
+		flags.label = label
+		if p.label != "" {
+			flags.label = p.label
+		}
+
+		if flags.dnd {
+			setDND(p.kind == "work")
+		}
+		rec.event("phase", fmt.Sprintf("%v %v", p.mode, p.duration))
+		t := newTimer(clock, p.mode, p.duration)
+		t.rec = rec
+		t.phased = true
+		t.kind = p.kind
+		t.overview = overview(phases, i)
+		if p.status != nil {
+			if status := p.status(); status != "" {
+				t.overview = append([]string{status}, t.overview...)
+			}
+		}
+		// the keys go back to how they were, still locked under -lock
+		wasLocked := keys.isLocked()
+		if p.overlay {
+			t.overlay = true
+			screen.alternate(true)
+			keys.lock()
+		}
+		running := currentTheme.running
+		if p.color != "" {
+			currentTheme.running = p.color
+		}
+		runHook(p.onStart, "start", p.name())
+		ret := t.run(ctx, c, e)
+		currentTheme.running = running
+		if p.overlay {
+			if !wasLocked {
+				keys.unlock()
+			}
+			screen.alternate(false)
+		}
+		if t.completed {
+			runHook(p.onEnd, "end", p.name())
+			playSound(p.sound)
+		}
+		switch {
+		case t.jump > 0:
+			rec.event("skip", "")
+		case t.jump < 0:
+			rec.event("back", "")
+			i -= 2
+			if i < -1 {
+				i = -1
+			}
+		case ret != 0 || t.quit:
+			return ret, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// picker picks the duration of a timer or countdown started without one.
+// Digits are typed in from the right, like on a microwave, so 130 is a
+// minute and a half, and the arrows pick hours, minutes or seconds and
+// change them.
+type picker struct {
+	digits string // what was typed, at most six digits
+	field  int    // 0 for hours, 1 for minutes, 2 for seconds
+}
+
+const pickerDigits = 6
+
+// fields are the hours, minutes and seconds typed in, which need not be
+// under 60 when typed, like 90 for ninety seconds
+func (p *picker) fields() [3]int {
+	s := strings.Repeat("0", pickerDigits-len(p.digits)) + p.digits
+	var f [3]int
+	for i := range f {
+		f[i], _ = strconv.Atoi(s[2*i : 2*i+2])
+	}
+	return f
+}
+
+func (p *picker) duration() time.Duration {
+	f := p.fields()
+	return time.Duration(f[0])*time.Hour + time.Duration(f[1])*time.Minute + time.Duration(f[2])*time.Second
+}
+
+// step changes the picked field by n, wrapping round within it
+func (p *picker) step(n int) {
+	f := p.fields()
+	f[p.field] = ((f[p.field]+n)%100 + 100) % 100
+	if p.field > 0 {
+		f[p.field] %= 60
+	}
+	p.digits = strings.TrimLeft(fmt.Sprintf("%02d%02d%02d", f[0], f[1], f[2]), "0")
+}
+
+// press acts on a key and reports whether the picker is done
+func (p *picker) press(k key) bool {
+	switch k.name {
+	case "left":
+		p.field = (p.field + 2) % 3
+	case "right":
+		p.field = (p.field + 1) % 3
+	case "up":
+		p.step(1)
+	case "down":
+		p.step(-1)
+	case "":
+		switch c := k.char; {
+		case c >= '0' && c <= '9':
+			if len(p.digits) < pickerDigits && (c != '0' || p.digits != "") {
+				p.digits += string(c)
+			}
+		case c == '\b' || c == 0x7f:
+			if p.digits != "" {
+				p.digits = p.digits[:len(p.digits)-1]
+			}
+		case c == '\r' || c == '\n':
+			return p.duration() > 0
+		}
+	}
+	return false
+}
+
+func (p *picker) draw() {
+	f := p.fields()
+	parts := make([]string, 3)
+	for i, n := range f {
+		parts[i] = fmt.Sprintf("%02d", n)
+		if i == p.field {
+			if screen.plain {
+				parts[i] = "_" + parts[i] + "_"
+			} else {
+				parts[i] = "\x1b[7m" + parts[i] + "\x1b[0m"
+			}
+		}
+	}
+	screen.draw([]string{"Duration: [" + strings.Join(parts, ":") + "]  digits or arrows, enter to start, q to quit"})
+}
+
+// canPick reports whether there is someone at a terminal to pick a
+// duration, rather than a script that wants the parse error
+func canPick() bool {
+	f, ok := screen.w.(*os.File)
+	return !flags.batch && ok && term.IsTerminal(int(f.Fd())) && term.IsTerminal(int(os.Stdin.Fd()))
+}
+
+// pickDuration lets the user pick a duration on the terminal, and returns
+// false if they quit instead
+func pickDuration() (time.Duration, bool, error) {
+	p := &picker{field: 1}
+	p.draw()
+	picked := false
+	err := askKeys(func(k key) bool {
+		if picked = p.press(k); !picked {
+			p.draw()
+		}
+		return picked
+	})
+	return p.duration(), picked && err == nil, err
+}
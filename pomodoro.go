@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// pomodoroPhases is one round of the pomodoro technique: -cycles pomodoros
+// of -work each, with a -short break between them and a -long break at
+// the end. Work phases show the progress toward the daily -goal, and with
+// -break-screen breaks take over the terminal.
+func pomodoroPhases() []phase {
+	var phases []phase
+	for i := 1; i <= flags.cycles; i++ {
+		phases = append(phases, phase{
+			mode:     COUNTDOWN,
+			duration: flags.work,
+			label:    fmt.Sprintf("Pomodoro %d/%d", i, flags.cycles),
+			kind:     "work",
+			status:   goalProgress,
+		})
+		if i < flags.cycles {
+			phases = append(phases, phase{mode: COUNTDOWN, duration: flags.short, label: "Short break", kind: "short", overlay: flags.breakScreen})
+		} else {
+			phases = append(phases, phase{mode: COUNTDOWN, duration: flags.long, label: "Long break", kind: "long", overlay: flags.breakScreen})
+		}
+	}
+	return phases
+}
+
+// runPomodoro runs rounds of pomodoros until it is quit
+func runPomodoro(ctx context.Context, clock Clock, rec *recorder, c chan key, e chan int) int {
+	phases := pomodoroPhases()
+	if flags.after > 0 {
+		phases = append([]phase{delayPhase(flags.after)}, phases...)
+	}
+	for {
+		if ret, quit := runPhases(ctx, clock, phases, rec, c, e); quit {
+			return ret
+		}
+		phases = pomodoroPhases()
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// batteryRefresh is the fastest the display is redrawn on battery
+const batteryRefresh = time.Second
+
+// onBattery is set when -battery-saver is on and the machine was running on
+// battery when it was last checked
+var onBattery bool
+
+// batteryCheck is how often a running timer checks the power supply again,
+// for a laptop plugged in or unplugged while it runs
+const batteryCheck = time.Minute
+
+// checkBattery sets onBattery for the power supply now
+func checkBattery() {
+	onBattery = flags.batterySaver && runningOnBattery()
+}
+
+// recheckBattery checks the power supply again once batteryCheck has
+// passed on the timer's clock, and reports whether it did
+func (t *timer) recheckBattery() bool {
+	now := t.clock.Now()
+	if now.Sub(t.batteryAt) < batteryCheck {
+		return false
+	}
+	t.batteryAt = now
+	checkBattery()
+	return true
+}
+
+// powerSupplies is where Linux describes the power supplies
+var powerSupplies = "/sys/class/power_supply"
+
+// runningOnBattery reports whether the machine is drawing from a battery:
+// no mains supply is online and a battery is discharging. Machines without
+// a battery, or without sysfs, are never on battery.
+func runningOnBattery() bool {
+	dirs, err := filepath.Glob(filepath.Join(powerSupplies, "*"))
+	if err != nil {
+		return false
+	}
+	read := func(dir, name string) string {
+		b, _ := os.ReadFile(filepath.Join(dir, name))
+		return strings.TrimSpace(string(b))
+	}
+	discharging := false
+	for _, dir := range dirs {
+		switch read(dir, "type") {
+		case "Mains", "USB":
+			if read(dir, "online") == "1" {
+				return false
+			}
+		case "Battery":
+			if read(dir, "status") == "Discharging" {
+				discharging = true
+			}
+		}
+	}
+	return discharging
+}
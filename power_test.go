@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestRecheckBattery unplugs a laptop while a countdown runs and checks it
+// is noticed once a minute has passed on the timer's clock
+func TestRecheckBattery(t *testing.T) {
+	savedFlags, savedScreen, savedSupplies := flags, screen, powerSupplies
+	t.Cleanup(func() {
+		flags, screen, powerSupplies = savedFlags, savedScreen, savedSupplies
+		onBattery = false
+	})
+	powerSupplies = t.TempDir()
+	supply := func(name string, files map[string]string) {
+		dir := filepath.Join(powerSupplies, name)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		for file, s := range files {
+			if err := os.WriteFile(filepath.Join(dir, file), []byte(s+"\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	supply("AC", map[string]string{"type": "Mains", "online": "1"})
+	supply("BAT0", map[string]string{"type": "Battery", "status": "Charging"})
+
+	sim, err := startSimulation("-battery-saver -c 5m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sim.stop()
+	if onBattery {
+		t.Fatal("on battery while plugged in")
+	}
+	supply("AC", map[string]string{"online": "0"})
+	supply("BAT0", map[string]string{"status": "Discharging"})
+	sim.advance(30 * time.Second)
+	if onBattery {
+		t.Error("power supply checked again before a minute had passed")
+	}
+	sim.advance(31 * time.Second)
+	if !onBattery {
+		t.Error("unplugging not noticed after a minute")
+	}
+	if iv := refreshInterval(); iv != batteryRefresh {
+		t.Errorf("refreshing every %v on battery, want %v", iv, batteryRefresh)
+	}
+}
@@ -0,0 +1,622 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A preset is a timer kept under a name in presets.toml in the config
+// directory, or in the [presets] table of a config file, and started with
+// `gutimer name`:
+//
+//	tea = "countdown -label tea 4m"
+//	standup = "countdown -bar -label \"stand up\" 15m"
+//	pasta = "routine pasta"
+//
+// The value is a mode and the arguments for it, quoted like a shell would.
+// Routines kept in the routines directory can be run by name, so a preset
+// of a routine only has to name it. Flags after the name of a preset go in
+// front of its own, which have the last word.
+
+// presetModes are the words a preset starts with and what they stand for
+var presetModes = map[string]string{
+	"timer":     "-t",
+	"countdown": "-c",
+	"stopwatch": "-s",
+	"alarm":     "-a",
+	"race":      "-r",
+	"pomodoro":  "-p",
+	"routine":   "routine",
+}
+
+// subcommands are handled by main before presets, so none can be named so
+var subcommands = map[string]bool{
+	"replay": true, "join": true, "systemd-export": true, "next": true,
+	"run": true, "routine": true, "stats": true, "last": true,
+	"selftest": true, "preset": true, "resume": true,
+	"simulate": true, "again": true,
+	"attach": true,
+}
+
+func presetsFile() string {
+	return filepath.Join(configDir(), "presets.toml")
+}
+
+func routineDir() string {
+	return filepath.Join(configDir(), "routines")
+}
+
+// readPresets returns the presets by name, none if there are no files.
+// Those in presets.toml come first, then the [presets] tables of the config
+// files, so a .gutimer.toml can add its own or change the user's.
+func readPresets() (map[string]string, error) {
+	presets := map[string]string{}
+	err := readSettings(presetsFile(), func(key, value string) error {
+		presets[key] = value
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return presets, err
+	}
+	for i, path := range configFiles() {
+		project := i > 0
+		err := readSettings(path, func(key, value string) error {
+			if name := strings.TrimPrefix(key, "presets."); name != key {
+				if project {
+					if err := checkProjectPreset(value); err != nil {
+						return fmt.Errorf("preset %s: %v", name, err)
+					}
+				}
+				presets[name] = value
+			}
+			return nil
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return presets, err
+		}
+	}
+	return presets, nil
+}
+
+// checkProjectPreset makes sure a preset from a .gutimer.toml sets none of
+// the flags the file itself can't, and is not a routine, whose steps can
+// run commands
+func checkProjectPreset(value string) error {
+	words, err := splitWords(value)
+	if err != nil {
+		return err
+	}
+	if len(words) > 0 && words[0] == "routine" {
+		return fmt.Errorf("routines cannot be started from %s", projectName)
+	}
+	for _, w := range words {
+		if !strings.HasPrefix(w, "-") {
+			continue
+		}
+		name := strings.SplitN(strings.TrimLeft(w, "-"), "=", 2)[0]
+		if projectUnsafe[name] {
+			return fmt.Errorf("%s cannot be set in %s", name, projectName)
+		}
+	}
+	return nil
+}
+
+// checkPresetName makes sure a name can be given to a preset or routine
+func checkPresetName(name string) error {
+	if name == "" || name == "." || name == ".." || filepath.Base(name) != name ||
+		strings.HasPrefix(name, "-") || strings.ContainsAny(name, " \t=#\"") || subcommands[name] {
+		return fmt.Errorf("invalid preset name %q", name)
+	}
+	return nil
+}
+
+// presetArgs turns a preset into the command line it stands for, with the
+// flags in extra put after the mode
+func presetArgs(value string, extra []string) ([]string, error) {
+	words, err := splitWords(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(words) == 0 {
+		return nil, fmt.Errorf("empty preset")
+	}
+	mode, ok := presetModes[words[0]]
+	if !ok {
+		return nil, fmt.Errorf("unknown mode %q", words[0])
+	}
+	args := append([]string{mode}, extra...)
+	return append(args, words[1:]...), nil
+}
+
+// lookupPreset returns the command line for `gutimer name extra...`, or
+// false if there is no preset called name
+func lookupPreset(name string, extra []string) ([]string, bool, error) {
+	if subcommands[name] || strings.HasPrefix(name, "-") {
+		return nil, false, nil
+	}
+	presets, err := readPresets()
+	if err != nil {
+		return nil, false, err
+	}
+	value, ok := presets[name]
+	if !ok {
+		return nil, false, nil
+	}
+	args, err := presetArgs(value, extra)
+	if err != nil {
+		return nil, false, fmt.Errorf("preset %s: %v", name, err)
+	}
+	return args, true, nil
+}
+
+// splitWords splits s at spaces, keeping anything in single or double
+// quotes together
+func splitWords(s string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+	for _, r := range s {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			word.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in %q", s)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// openRoutine opens a routine file, or the routine kept under that name
+// if there is no such file
+func openRoutine(name string) (*os.File, error) {
+	f, err := os.Open(name)
+	if os.IsNotExist(err) && checkPresetName(name) == nil {
+		if kept, err := os.Open(filepath.Join(routineDir(), name)); err == nil {
+			return kept, nil
+		}
+	}
+	return f, err
+}
+
+// keptRoutines returns the names of the routines in the routines directory
+func keptRoutines() ([]string, error) {
+	infos, err := ioutil.ReadDir(routineDir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, fi := range infos {
+		if fi.Mode().IsRegular() && checkPresetName(fi.Name()) == nil && !strings.HasSuffix(fi.Name(), ".tmp") {
+			names = append(names, fi.Name())
+		}
+	}
+	return names, nil
+}
+
+// presetHeader starts a file of presets written by gutimer preset export.
+// After it come tab separated lines: "preset name value" for each preset
+// and "routine name line" for each line of a routine.
+const presetHeader = "gutimer-presets"
+
+// bundle is the presets and routines in an exported file
+type bundle struct {
+	presets  map[string]string
+	routines map[string][]string // lines of each routine
+	order    []string            // "preset name" and "routine name" as read
+}
+
+func newBundle() *bundle {
+	return &bundle{presets: map[string]string{}, routines: map[string][]string{}}
+}
+
+func (b *bundle) addPreset(name, value string) {
+	if _, ok := b.presets[name]; !ok {
+		b.order = append(b.order, "preset "+name)
+	}
+	b.presets[name] = value
+}
+
+func (b *bundle) addRoutine(name string, lines []string) {
+	if _, ok := b.routines[name]; !ok {
+		b.order = append(b.order, "routine "+name)
+	}
+	b.routines[name] = lines
+}
+
+func (b *bundle) write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, presetHeader)
+	for _, item := range b.order {
+		kind := strings.Fields(item)
+		switch kind[0] {
+		case "preset":
+			fmt.Fprintf(bw, "preset\t%s\t%s\n", kind[1], b.presets[kind[1]])
+		case "routine":
+			for _, line := range b.routines[kind[1]] {
+				fmt.Fprintf(bw, "routine\t%s\t%s\n", kind[1], line)
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// readBundle reads and checks a file written by gutimer preset export
+func readBundle(r io.Reader, name string) (*bundle, error) {
+	sc := bufio.NewScanner(r)
+	if !sc.Scan() || sc.Text() != presetHeader {
+		if err := sc.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%s: not a gutimer presets file", name)
+	}
+	b := newBundle()
+	for n := 2; sc.Scan(); n++ {
+		if sc.Text() == "" {
+			continue
+		}
+		fields := strings.SplitN(sc.Text(), "\t", 3)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("%s:%d: malformed line", name, n)
+		}
+		if err := checkPresetName(fields[1]); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", name, n, err)
+		}
+		switch fields[0] {
+		case "preset":
+			if _, err := presetArgs(fields[2], nil); err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", name, n, err)
+			}
+			b.addPreset(fields[1], fields[2])
+		case "routine":
+			b.addRoutine(fields[1], append(b.routines[fields[1]], fields[2]))
+		default:
+			return nil, fmt.Errorf("%s:%d: unknown kind %q", name, n, fields[0])
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	for routine, lines := range b.routines {
+		if _, err := readRoutine(strings.NewReader(strings.Join(lines, "\n")), name+": routine "+routine); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// preset runs `gutimer preset list|export|import`
+func preset(args []string) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "list":
+			return listPresets(args[1:])
+		case "export":
+			return exportPresets(args[1:])
+		case "import":
+			return importPresets(args[1:])
+		}
+	}
+	fmt.Fprintln(os.Stderr, "Usage: gutimer preset list | export [name...] | import [-f] [-trust] file|-")
+	return 1
+}
+
+// listPresets prints the presets and the routines kept by name
+func listPresets(args []string) int {
+	if len(args) != 0 {
+		fmt.Fprintln(os.Stderr, "Usage: gutimer preset list")
+		return 1
+	}
+	presets, err := readPresets()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to read presets: %v\n", err)
+		return 1
+	}
+	routines, err := keptRoutines()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to read routines: %v\n", err)
+		return 1
+	}
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("%-16s %s\n", name, presets[name])
+	}
+	for _, name := range routines {
+		fmt.Printf("%-16s (routine)\n", name)
+	}
+	return 0
+}
+
+// exportPresets writes the named presets and routines, or all of them, to
+// stdout as one file for gutimer preset import. A preset of a routine
+// brings the routine with it.
+func exportPresets(args []string) int {
+	presets, err := readPresets()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to read presets: %v\n", err)
+		return 1
+	}
+	routines, err := keptRoutines()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to read routines: %v\n", err)
+		return 1
+	}
+	names := args
+	if len(names) == 0 {
+		for name := range presets {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		names = append(names, routines...)
+	}
+
+	b := newBundle()
+	addRoutine := func(name string) (bool, error) {
+		if checkPresetName(name) != nil {
+			return false, nil
+		}
+		content, err := ioutil.ReadFile(filepath.Join(routineDir(), name))
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		b.addRoutine(name, strings.Split(strings.TrimRight(string(content), "\n"), "\n"))
+		return true, nil
+	}
+	for _, name := range names {
+		value, found := presets[name]
+		if found {
+			b.addPreset(name, value)
+			// a preset of a kept routine is no use without it
+			if words, _ := splitWords(value); len(words) == 2 && words[0] == "routine" {
+				if _, err := addRoutine(words[1]); err != nil {
+					fmt.Fprintf(os.Stderr, "Unable to read routine: %v\n", err)
+					return 1
+				}
+			}
+		}
+		kept, err := addRoutine(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to read routine: %v\n", err)
+			return 1
+		}
+		if !found && !kept {
+			fmt.Fprintf(os.Stderr, "No preset or routine called %s\n", name)
+			return 1
+		}
+	}
+	if err := b.write(os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to write presets: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// importPresets adds the presets and routines in a file written by gutimer
+// preset export. Ones that are already there with something else are only
+// replaced with -f, ones that run commands, write files or listen on the
+// network only with -trust, and nothing is imported unless everything can
+// be. Presets are added to the end of presets.toml, where they win over any
+// earlier ones with the same name.
+func importPresets(args []string) int {
+	fs := flag.NewFlagSet("preset import", flag.ExitOnError)
+	force := fs.Bool("f", false, "replace presets and routines that are already there")
+	trust := fs.Bool("trust", false, "import presets and routines that run commands, write files or listen on the network")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: gutimer preset import [-f] [-trust] file|-")
+		return 1
+	}
+	name := fs.Arg(0)
+	r := io.Reader(os.Stdin)
+	if name == "-" {
+		name = "stdin"
+	} else {
+		f, err := os.Open(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to read presets: %v\n", err)
+			return 1
+		}
+		defer f.Close()
+		r = f
+	}
+	b, err := readBundle(r, name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to read presets: %v\n", err)
+		return 1
+	}
+	presets, err := readPresets()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to read presets: %v\n", err)
+		return 1
+	}
+
+	// work out what changes before touching anything
+	var add bytes.Buffer
+	var changed []string
+	conflict := false
+	for _, item := range b.order {
+		kind := strings.Fields(item)
+		var old, value string
+		var exists bool
+		switch kind[0] {
+		case "preset":
+			old, exists = presets[kind[1]]
+			value = b.presets[kind[1]]
+		case "routine":
+			content, err := ioutil.ReadFile(filepath.Join(routineDir(), kind[1]))
+			old, exists = string(content), err == nil
+			value = strings.Join(b.routines[kind[1]], "\n") + "\n"
+		}
+		if exists && old == value {
+			continue
+		}
+		if exists && !*force {
+			fmt.Fprintf(os.Stderr, "There is already a different %s, -f to replace it\n", item)
+			conflict = true
+			continue
+		}
+		changed = append(changed, item)
+		if kind[0] == "preset" {
+			fmt.Fprintf(&add, "%s = %s\n", kind[1], strconv.Quote(value))
+		}
+	}
+	if conflict {
+		return 1
+	}
+
+	// whoever wrote the file need not be the one importing it, so what it
+	// would run is shown before any of it goes into the config
+	var unsafe []string
+	for _, item := range changed {
+		unsafe = append(unsafe, unsafeImports(b, item)...)
+	}
+	if len(unsafe) > 0 {
+		for _, u := range unsafe {
+			fmt.Fprintln(os.Stderr, u)
+		}
+		if !*trust {
+			fmt.Fprintf(os.Stderr, "Nothing imported from %s, -trust to import it as listed above\n", name)
+			return 1
+		}
+	}
+
+	for _, item := range changed {
+		kind := strings.Fields(item)
+		if kind[0] != "routine" {
+			continue
+		}
+		if err := writeRoutine(kind[1], b.routines[kind[1]]); err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to write routine: %v\n", err)
+			return 1
+		}
+	}
+	if add.Len() > 0 {
+		if err := appendPresets(fmt.Sprintf("# from %s\n", name), add.Bytes()); err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to write presets: %v\n", err)
+			return 1
+		}
+	}
+	for _, item := range changed {
+		fmt.Fprintf(os.Stderr, "Imported %s\n", item)
+	}
+	return 0
+}
+
+// unsafeImports lists what item, a preset or routine from b, would run,
+// write or listen on: the flags of a preset a .gutimer.toml can't set and
+// the on-start and on-end commands of a routine
+func unsafeImports(b *bundle, item string) []string {
+	kind := strings.Fields(item)
+	var unsafe []string
+	switch kind[0] {
+	case "preset":
+		words, _ := splitWords(b.presets[kind[1]])
+		for i, w := range words {
+			if !strings.HasPrefix(w, "-") {
+				continue
+			}
+			kv := strings.SplitN(strings.TrimLeft(w, "-"), "=", 2)
+			if !projectUnsafe[kv[0]] {
+				continue
+			}
+			if len(kv) == 1 && i+1 < len(words) {
+				kv = append(kv, words[i+1])
+			}
+			value := strings.Join(kv[1:], "")
+			// a -warn without run: only rings or notifies
+			if kv[0] == "warn" && !strings.Contains(value, "run:") {
+				continue
+			}
+			unsafe = append(unsafe, fmt.Sprintf("%s sets -%s %s", item, kv[0], value))
+		}
+	case "routine":
+		for _, line := range b.routines[kind[1]] {
+			p, ok, _ := parseRoutineLine(line)
+			if !ok {
+				continue
+			}
+			if p.onStart != "" {
+				unsafe = append(unsafe, fmt.Sprintf("%s runs on-start=%s", item, p.onStart))
+			}
+			if p.onEnd != "" {
+				unsafe = append(unsafe, fmt.Sprintf("%s runs on-end=%s", item, p.onEnd))
+			}
+		}
+	}
+	return unsafe
+}
+
+// writeRoutine keeps a routine under name, renaming it into place so the
+// routine is never seen half written
+func writeRoutine(name string, lines []string) error {
+	if err := os.MkdirAll(routineDir(), 0755); err != nil {
+		return err
+	}
+	path := filepath.Join(routineDir(), name)
+	if err := ioutil.WriteFile(path+".tmp", []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// appendPresets adds lines to the end of presets.toml under a comment,
+// leaving what is there as it is
+func appendPresets(comment string, lines []byte) error {
+	if err := os.MkdirAll(configDir(), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(presetsFile(), os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	text := comment + string(lines)
+	if fi, err := f.Stat(); err == nil && fi.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, fi.Size()-1); err == nil && last[0] != '\n' {
+			text = "\n" + text
+		}
+		text = "\n" + text
+	}
+	if _, err := io.WriteString(f, text); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
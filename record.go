@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// recorder logs every event of a run with its offset from the start so the
+// session can be played back later with `gutimer replay`. A nil recorder
+// discards everything.
+//
+// The file is line based: a header naming the mode and duration followed by
+// tab separated "offset kind value" lines, offsets in nanoseconds.
+type recorder struct {
+	f     *os.File
+	w     *bufio.Writer
+	clock Clock
+	start time.Time
+}
+
+const recordHeader = "gutimer-record"
+
+func newRecorder(path string, clock Clock, mode Mode, duration time.Duration) (*recorder, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &recorder{f: f, w: bufio.NewWriter(f), clock: clock, start: clock.Now()}
+	fmt.Fprintf(r.w, "%s\t%v\t%v\n", recordHeader, mode, duration)
+	return r, nil
+}
+
+func (r *recorder) event(kind string, value string) {
+	events.emit(kind, value)
+	if r == nil {
+		return
+	}
+	fmt.Fprintf(r.w, "%d\t%s\t%s\n", r.clock.Now().Sub(r.start), kind, value)
+}
+
+func (r *recorder) tick(d time.Duration) {
+	r.event("tick", d.String())
+}
+
+func (r *recorder) key(k key) {
+	switch k.name {
+	case "":
+		r.event("key", strconv.QuoteRune(rune(k.char)))
+	case "paste":
+		r.event("key", "paste "+strconv.Quote(k.text))
+	default:
+		r.event("key", k.name)
+	}
+}
+
+func (r *recorder) Close() error {
+	if r == nil {
+		return nil
+	}
+	if err := r.w.Flush(); err != nil {
+		r.f.Close()
+		return err
+	}
+	return r.f.Close()
+}
+
+// replay plays back a file written by a recorder at the original pace, or
+// faster with -speed.
+func replay(args []string) int {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	speed := fs.Float64("speed", 1, "replay `factor` times faster than recorded")
+	displayFlags(fs)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: gutimer replay [-speed factor] [display flags] file")
+		return 1
+	}
+	if *speed <= 0 {
+		fmt.Fprintln(os.Stderr, "Speed must be greater than zero")
+		return 1
+	}
+	if err := checkDisplayFlags(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to open recording: %v\n", err)
+		return 1
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	if !sc.Scan() {
+		fmt.Fprintln(os.Stderr, "Recording is empty")
+		return 1
+	}
+	header := strings.Split(sc.Text(), "\t")
+	if len(header) != 3 || header[0] != recordHeader {
+		fmt.Fprintln(os.Stderr, "Not a gutimer recording")
+		return 1
+	}
+	mode, err := parseMode(header[1])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Bad recording header: %v\n", err)
+		return 1
+	}
+	total, err := time.ParseDuration(header[2])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Bad recording header: %v\n", err)
+		return 1
+	}
+	// a stopwatch never runs over, however long it goes
+	if mode == STOPWATCH {
+		total = 1<<63 - 1
+	}
+
+	clock := newScaledClock(realClock{}, *speed)
+	start := clock.Now()
+	// the display of a -hard countdown can be frozen on the last tick
+	var last, frozenAt time.Duration
+	frozen := false
+	for line := 2; sc.Scan(); line++ {
+		fields := strings.SplitN(sc.Text(), "\t", 3)
+		if len(fields) != 3 {
+			screen.finish()
+			fmt.Fprintf(os.Stderr, "line %d: malformed event\n", line)
+			return 1
+		}
+		offset, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			screen.finish()
+			fmt.Fprintf(os.Stderr, "line %d: %v\n", line, err)
+			return 1
+		}
+		// scaled time only advances with the real clock, so sleeping the
+		// scaled remainder divided by the factor lands on the event
+		if wait := time.Duration(offset) - clock.Now().Sub(start); wait > 0 {
+			time.Sleep(time.Duration(float64(wait) / *speed))
+		}
+		switch fields[1] {
+		case "tick":
+			d, err := time.ParseDuration(fields[2])
+			if err != nil {
+				screen.finish()
+				fmt.Fprintf(os.Stderr, "line %d: %v\n", line, err)
+				return 1
+			}
+			last = d
+			if frozen {
+				d = frozenAt
+			}
+			printElapsed(mode, total, d)
+		case "freeze":
+			frozen, frozenAt = fields[2] == "true", last
+		case "adjust":
+			// the time a countdown was made longer or shorter by, while a
+			// stopwatch's ticks have it already
+			if d, err := time.ParseDuration(fields[2]); err == nil && mode != STOPWATCH {
+				total += d
+				if total < 0 {
+					total = 0
+				}
+			}
+		case "phase":
+			// the next timer of a run with several takes over
+			words := strings.Fields(fields[2])
+			if len(words) != 2 {
+				screen.finish()
+				fmt.Fprintf(os.Stderr, "line %d: bad phase %q\n", line, fields[2])
+				return 1
+			}
+			m, err := parseMode(words[0])
+			if err != nil {
+				screen.finish()
+				fmt.Fprintf(os.Stderr, "line %d: %v\n", line, err)
+				return 1
+			}
+			d, err := time.ParseDuration(words[1])
+			if err != nil {
+				screen.finish()
+				fmt.Fprintf(os.Stderr, "line %d: %v\n", line, err)
+				return 1
+			}
+			screen.finish()
+			mode, total, frozen = m, d, false
+			if mode == STOPWATCH {
+				total = 1<<63 - 1
+			}
+		case "reset":
+			if d, err := time.ParseDuration(fields[2]); err == nil {
+				total = d
+			}
+		case "lap", "split":
+			if d, err := time.ParseDuration(fields[2]); err == nil {
+				screen.print(fmt.Sprintf("%s at %s\n", strings.Title(fields[1]), printDuration(d)))
+			}
+		case "unlap":
+			screen.print(fmt.Sprintf("Lap at %s undone\n", fields[2]))
+		case "note":
+			screen.print(fmt.Sprintf("Note: %s\n", fields[2]))
+		case "bell":
+			screen.bell()
+		case "key":
+			if flags.verbose {
+				screen.print(fmt.Sprintf("key %s\n", fields[2]))
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		screen.finish()
+		fmt.Fprintf(os.Stderr, "Error reading recording: %v\n", err)
+		return 1
+	}
+	screen.finish()
+	return 0
+}
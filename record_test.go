@@ -0,0 +1,55 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// replayFrame replays recording with args in front and returns the last
+// frame drawn
+func replayFrame(t *testing.T, recording string, args ...string) string {
+	t.Helper()
+	savedFlags, savedScreen, savedDepth := flags, screen, depth
+	t.Cleanup(func() { flags, screen, depth = savedFlags, savedScreen, savedDepth })
+	path := filepath.Join(t.TempDir(), "run.rec")
+	if err := os.WriteFile(path, []byte(recording), 0644); err != nil {
+		t.Fatal(err)
+	}
+	screen = &layout{w: ioutil.Discard}
+	args = append(append([]string{"-speed", "1000", "-battery-saver=false"}, args...), path)
+	if code := replay(args); code != 0 {
+		t.Fatalf("replay exited with %d", code)
+	}
+	return strings.Join(screen.last, "\n")
+}
+
+func TestReplayAdjusted(t *testing.T) {
+	got := replayFrame(t, "gutimer-record\tcountdown\t5m0s\n"+
+		"0\ttick\t0s\n"+
+		"1000\tadjust\t10m0s\n"+
+		"2000\ttick\t910ms\n", "-color", "never")
+	if !strings.Contains(got, "[00:14:59.09]") {
+		t.Errorf("adjusted countdown replayed as %q, want [00:14:59.09]", got)
+	}
+}
+
+func TestReplayFrozen(t *testing.T) {
+	got := replayFrame(t, "gutimer-record\tcountdown\t1m0s\n"+
+		"0\ttick\t1s\n"+
+		"1000\tfreeze\ttrue\n"+
+		"2000\ttick\t5s\n", "-color", "never")
+	if !strings.Contains(got, "[00:00:59.00]") {
+		t.Errorf("frozen countdown replayed as %q, want [00:00:59.00]", got)
+	}
+}
+
+func TestReplayStopwatch(t *testing.T) {
+	got := replayFrame(t, "gutimer-record\tstopwatch\t0s\n"+
+		"0\ttick\t500ms\n", "-color", "16")
+	if !strings.Contains(got, "[00:00:00.50]") || strings.Contains(got, "\x1b["+red+"m") {
+		t.Errorf("stopwatch replayed as %q, want it at [00:00:00.50] and not red", got)
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// resumeKeep is how long the time left on a timer or countdown quit early
+// is kept for gutimer resume
+const resumeKeep = 7 * 24 * time.Hour
+
+// resumeState is what is kept of a timer or countdown quit early
+type resumeState struct {
+	Mode      string    `json:"mode,omitempty"` // countdown if not given
+	Remaining float64   `json:"remaining"`      // seconds
+	Label     string    `json:"label,omitempty"`
+	Quit      time.Time `json:"quit"`
+}
+
+func resumeDir() string {
+	return filepath.Join(dataDir(), "resume")
+}
+
+// checkResumeID makes sure an id can only name a file in the resume
+// directory
+func checkResumeID(id string) error {
+	if _, err := hex.DecodeString(id); err != nil || id == "" {
+		return fmt.Errorf("invalid resume id %q", id)
+	}
+	return nil
+}
+
+// keepResume keeps the time left on a timer or countdown quit before its
+// end under a new id, and says how to carry on with it, from any terminal
+func (t *timer) keepResume() {
+	if t.detached {
+		return
+	}
+	remaining := t.duration - t.elapsed
+	if t.mode != TIMER && t.mode != COUNTDOWN || t.completed || remaining <= 0 {
+		return
+	}
+	id, err := saveResume(resumeState{Mode: t.mode.String(), Remaining: remaining.Seconds(), Label: flags.label, Quit: time.Now()})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to keep the time left: %v\n", err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%s left, gutimer resume %s to carry on\n", printDuration(remaining), id)
+}
+
+// saveResume writes state under a new id and clears out old ones
+func saveResume(state resumeState) (string, error) {
+	if err := os.MkdirAll(resumeDir(), 0755); err != nil {
+		return "", err
+	}
+	pruneResume()
+	b, err := json.Marshal(state)
+	if err != nil {
+		return "", err
+	}
+	for {
+		id := make([]byte, 3)
+		if _, err := rand.Read(id); err != nil {
+			return "", err
+		}
+		name := hex.EncodeToString(id)
+		// O_EXCL so two countdowns quit at once never share an id
+		f, err := os.OpenFile(filepath.Join(resumeDir(), name+".json"), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if _, err := f.Write(append(b, '\n')); err != nil {
+			f.Close()
+			return "", err
+		}
+		return name, f.Close()
+	}
+}
+
+// pruneResume removes what was kept longer than resumeKeep ago
+func pruneResume() {
+	infos, err := ioutil.ReadDir(resumeDir())
+	if err != nil {
+		return
+	}
+	for _, fi := range infos {
+		if time.Since(fi.ModTime()) > resumeKeep {
+			os.Remove(filepath.Join(resumeDir(), fi.Name()))
+		}
+	}
+}
+
+// readResume returns what was kept under id
+func readResume(id string) (resumeState, error) {
+	var state resumeState
+	if err := checkResumeID(id); err != nil {
+		return state, err
+	}
+	b, err := ioutil.ReadFile(filepath.Join(resumeDir(), id+".json"))
+	if os.IsNotExist(err) {
+		return state, fmt.Errorf("nothing to resume under %s", id)
+	}
+	if err != nil {
+		return state, err
+	}
+	if err := json.Unmarshal(b, &state); err != nil {
+		return state, fmt.Errorf("%s: %v", id, err)
+	}
+	return state, nil
+}
+
+// listResume prints the ids of the countdowns that can be resumed, the
+// latest first
+func listResume() int {
+	infos, err := ioutil.ReadDir(resumeDir())
+	if err != nil && !os.IsNotExist(err) {
+		fmt.Fprintf(os.Stderr, "Unable to read countdowns to resume: %v\n", err)
+		return 1
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ModTime().After(infos[j].ModTime()) })
+	n := 0
+	for _, fi := range infos {
+		id := strings.TrimSuffix(fi.Name(), ".json")
+		state, err := readResume(id)
+		if err != nil || time.Since(state.Quit) > resumeKeep {
+			continue
+		}
+		line := fmt.Sprintf("%s  %s  %s left", id, state.Quit.Local().Format("Mon Jan 2 15:04"), printDuration(time.Duration(state.Remaining*float64(time.Second))))
+		if state.Label != "" {
+			line += "  " + state.Label
+		}
+		fmt.Println(line)
+		n++
+	}
+	if n == 0 {
+		fmt.Fprintln(os.Stderr, "No countdowns to resume")
+		return 1
+	}
+	return 0
+}
+
+// parseResume parses `gutimer resume [flags] id`, which carries on with a
+// timer or countdown quit early with the time it had left, or lists the
+// ones there are without an id. Whoever resumes one first takes it.
+func parseResume(args []string) (Mode, time.Duration) {
+	fs := flag.NewFlagSet("resume", flag.ExitOnError)
+	fs.BoolVar(&flags.verbose, "v", false, "verbose")
+	fs.BoolVar(&flags.history, "history", true, "add finished timers to the history file")
+	fs.BoolVar(&flags.notify, "notify", false, "show a desktop notification when the countdown ends")
+	fs.StringVar(&flags.label, "label", "", "show `text` in front of the time instead of the label it had")
+	displayFlags(fs)
+	fs.Parse(args)
+	if fs.NArg() > 1 {
+		fmt.Fprintln(os.Stderr, "Usage: gutimer resume [-label text] [display flags] [id]")
+		os.Exit(1)
+	}
+	if err := checkDisplayFlags(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if fs.NArg() == 0 {
+		os.Exit(listResume())
+	}
+
+	id := fs.Arg(0)
+	state, err := readResume(id)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to resume: %v\n", err)
+		os.Exit(1)
+	}
+	// only one of the removes can work, so only one terminal gets it
+	if err := os.Remove(filepath.Join(resumeDir(), id+".json")); os.IsNotExist(err) {
+		fmt.Fprintf(os.Stderr, "Unable to resume: %s was resumed already\n", id)
+		os.Exit(1)
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to resume: %v\n", err)
+		os.Exit(1)
+	}
+	mode := COUNTDOWN
+	if state.Mode != "" {
+		if mode, err = parseMode(state.Mode); err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to resume: %s: %v\n", id, err)
+			os.Exit(1)
+		}
+	}
+	if flags.label == "" {
+		flags.label = state.Label
+	}
+	return mode, time.Duration(state.Remaining * float64(time.Second))
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestResumeTimer quits a timer early and checks gutimer resume carries
+// on with a timer for the time it had left
+func TestResumeTimer(t *testing.T) {
+	savedFlags := flags
+	t.Cleanup(func() { flags = savedFlags })
+	sim := startTest(t, "-t 1m")
+	sim.advance(20 * time.Second)
+	sim.keys("q")
+	if !sim.over() {
+		t.Fatal("timer still running after q")
+	}
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	sim.t.keepResume()
+
+	paths, err := filepath.Glob(filepath.Join(resumeDir(), "*.json"))
+	if err != nil || len(paths) != 1 {
+		t.Fatalf("kept %v, %v, want one id", paths, err)
+	}
+	id := strings.TrimSuffix(filepath.Base(paths[0]), ".json")
+	mode, remaining := parseResume([]string{"-battery-saver=false", id})
+	if mode != TIMER || remaining != 40*time.Second {
+		t.Errorf("resumed as %v with %v left, want timer with 40s", mode, remaining)
+	}
+	if _, err := os.Stat(paths[0]); !os.IsNotExist(err) {
+		t.Errorf("%s still kept after resuming it: %v", id, err)
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// A routine is a plan of timers to run one after another, one to a line:
+//
+//	# pasta
+//	10m boil water
+//	8m  cook
+//	stopwatch eat
+//
+// Each line is a duration or "stopwatch", then a label. Blank lines and
+// lines starting with # are skipped. Options for the phase can follow, each
+// after a |:
+//
+//	8m cook | on-end=./notify-pasta.sh | sound=ding.wav | color=blue
+//
+// on-start and on-end are run by the shell as the phase starts and when it
+// runs to its end, and can have pipes of their own. sound is played at the
+// end, and color is the color of the time while it runs.
+
+// routineOptions set the options of a phase from a routine line
+var routineOptions = map[string]func(p *phase, value string) error{
+	"on-start": func(p *phase, value string) error {
+		p.onStart = value
+		return nil
+	},
+	"on-end": func(p *phase, value string) error {
+		p.onEnd = value
+		return nil
+	},
+	"sound": func(p *phase, value string) error {
+		p.sound = value
+		return nil
+	},
+	"color": func(p *phase, value string) error {
+		c, err := parseColor(value)
+		p.color = c
+		return err
+	},
+}
+
+// routineCommands are the options run by the shell, which may have pipes
+var routineCommands = map[string]bool{"on-start": true, "on-end": true}
+
+func optionName(opt string) string {
+	return strings.TrimSpace(strings.SplitN(opt, "=", 2)[0])
+}
+
+func isOption(opt string) bool {
+	_, ok := routineOptions[optionName(opt)]
+	return ok && strings.Contains(opt, "=")
+}
+
+// parseRoutineLine parses a line of a routine. It reports false for lines
+// without a phase.
+func parseRoutineLine(line string) (phase, bool, error) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return phase{}, false, nil
+	}
+	parts := strings.Split(line, "|")
+	words := strings.Fields(parts[0])
+	if len(words) == 0 {
+		return phase{}, false, fmt.Errorf("missing duration")
+	}
+	p := phase{mode: COUNTDOWN, label: strings.Join(words[1:], " ")}
+	if words[0] == "stopwatch" {
+		p.mode = STOPWATCH
+	} else {
+		d, err := parseDuration(words[0])
+		if err != nil {
+			return p, false, err
+		}
+		p.duration = d
+	}
+	// a | that isn't followed by an option is a pipe in the command before
+	// it, so each option runs to the next | with an option after it
+	var opts []string
+	for _, part := range parts[1:] {
+		if n := len(opts); n > 0 && routineCommands[optionName(opts[n-1])] && !isOption(part) {
+			opts[n-1] += "|" + part
+			continue
+		}
+		opts = append(opts, part)
+	}
+	for _, opt := range opts {
+		kv := strings.SplitN(opt, "=", 2)
+		key := strings.TrimSpace(kv[0])
+		set, ok := routineOptions[key]
+		if !ok || len(kv) != 2 {
+			return p, false, fmt.Errorf("unknown option %q", strings.TrimSpace(opt))
+		}
+		if err := set(&p, strings.TrimSpace(kv[1])); err != nil {
+			return p, false, fmt.Errorf("%s: %v", key, err)
+		}
+	}
+	return p, true, nil
+}
+
+// readRoutine reads the phases of a routine
+func readRoutine(r io.Reader, name string) ([]phase, error) {
+	var phases []phase
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		p, ok, err := parseRoutineLine(sc.Text())
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", name, n, err)
+		}
+		if ok {
+			phases = append(phases, p)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(phases) == 0 {
+		return nil, fmt.Errorf("%s: no phases", name)
+	}
+	return phases, nil
+}
+
+// parseRoutine parses `gutimer routine [flags] file`, which runs the phases
+// in file, or read from stdin for -, so other programs can make up plans.
+// A routine kept in the routines directory can be given by its name.
+func parseRoutine(args []string) (Mode, time.Duration) {
+	fs := flag.NewFlagSet("routine", flag.ExitOnError)
+	fs.BoolVar(&flags.verbose, "v", false, "verbose")
+	fs.BoolVar(&flags.history, "history", true, "add finished timers to the history file")
+	check := fs.Bool("check", false, "check the routine and print its schedule without running it")
+	displayFlags(fs)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: gutimer routine [-check] [display flags] file|name|-")
+		os.Exit(1)
+	}
+	if err := checkDisplayFlags(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	name := fs.Arg(0)
+	r := os.Stdin
+	if name == "-" {
+		name = "stdin"
+		// the plan takes up stdin, so keys come from the terminal
+		keysFromTerminal = true
+	} else {
+		f, err := openRoutine(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to read routine: %v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		r = f
+	}
+	phases, err := readRoutine(r, name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to read routine: %v\n", err)
+		os.Exit(1)
+	}
+	if *check {
+		os.Exit(checkRoutine(phases))
+	}
+	flags.routine = phases
+
+	var total time.Duration
+	for _, p := range phases {
+		total += p.duration
+	}
+	return ROUTINE, total
+}
+
+// checkRoutine prints when each phase of a routine starts and how long it
+// runs, and the total, and points out what looks wrong. It returns the exit
+// code for gutimer routine -check.
+func checkRoutine(phases []phase) int {
+	ret := 0
+	var at time.Duration
+	open := 0
+	for i, p := range phases {
+		length := "open"
+		if p.mode == STOPWATCH {
+			open++
+		} else {
+			length = printDuration(p.duration)
+		}
+		fmt.Printf("%2d. %-16s %-14s at +%s\n", i+1, p.name(), length, printDuration(at))
+		at += p.duration
+		if p.mode != STOPWATCH && p.duration <= 0 {
+			fmt.Fprintf(os.Stderr, "Phase %d has no length\n", i+1)
+			ret = 1
+		}
+		if p.sound != "" {
+			if _, err := os.Stat(p.sound); err != nil {
+				fmt.Fprintf(os.Stderr, "Phase %d: %v\n", i+1, err)
+				ret = 1
+			}
+		}
+		if p.mode == STOPWATCH && i < len(phases)-1 {
+			fmt.Fprintf(os.Stderr, "Phase %d is a stopwatch, so the ones after it wait for n\n", i+1)
+		}
+	}
+	total := fmt.Sprintf("Total: %s", printDuration(at))
+	if open > 0 {
+		total += fmt.Sprintf(" and %d open", open)
+	}
+	fmt.Println(total)
+	return ret
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// parseRun parses `gutimer run [flags] -- command args...`, which times a
+// command with a stopwatch
+func parseRun(args []string) (Mode, time.Duration) {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	fs.BoolVar(&flags.verbose, "v", false, "verbose")
+	fs.StringVar(&flags.label, "label", "", "show `text` in front of the time")
+	fs.IntVar(&flags.runs, "runs", 1, "run the command `n` times and summarize the timings")
+	fs.BoolVar(&flags.json, "json", false, "print the timings as JSON")
+	displayFlags(fs)
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: gutimer run [-runs n] [-json] [display flags] -- command [args...]")
+		os.Exit(1)
+	}
+	if flags.runs < 1 {
+		fmt.Fprintln(os.Stderr, "Runs must be at least 1")
+		os.Exit(1)
+	}
+	if err := checkDisplayFlags(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	flags.command = fs.Args()
+	return STOPWATCH, 0
+}
+
+// runResult is how one run of a command went
+type runResult struct {
+	Seconds float64 `json:"seconds"`
+	Status  int     `json:"status"`
+}
+
+// runSummary is the -json output of `gutimer run`
+type runSummary struct {
+	Command []string    `json:"command"`
+	Runs    []runResult `json:"runs"`
+	Min     float64     `json:"min"`
+	Mean    float64     `json:"mean"`
+	Max     float64     `json:"max"`
+	Stddev  float64     `json:"stddev"`
+}
+
+// runCommand runs the command of `gutimer run` -runs times, each under its
+// own stopwatch, and reports the timings. It stops at the first run that
+// fails or is quit and returns its exit status.
+func runCommand(ctx context.Context, clock Clock, rec *recorder, c chan key, e chan int) int {
+	// C-c goes to the command as well; let it decide whether to stop and
+	// stay around to report it
+	signal.Notify(make(chan os.Signal, 1), os.Interrupt)
+
+	label := flags.label
+	var durations []time.Duration
+	summary := runSummary{Command: flags.command}
+	ret := 0
+	for i := 1; i <= flags.runs; i++ {
+		if flags.runs > 1 {
+			flags.label = strings.TrimSpace(fmt.Sprintf("%s Run %d/%d", label, i, flags.runs))
+		}
+		t := newTimer(clock, STOPWATCH, 0)
+		t.rec = rec
+		cmd, err := startCommand(flags.command, t)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to run %s: %v\n", flags.command[0], err)
+			return 127
+		}
+		ret = t.run(ctx, c, e)
+		if !t.finished {
+			ret = stopCommand(cmd, t)
+		}
+		fmt.Fprintf(os.Stderr, "%s exited with status %d after %s\n", flags.command[0], ret, printDuration(t.elapsed))
+		durations = append(durations, t.elapsed)
+		summary.Runs = append(summary.Runs, runResult{Seconds: t.elapsed.Seconds(), Status: ret})
+		if ret != 0 || !t.finished {
+			break
+		}
+	}
+
+	min, mean, max, stddev := durationStats(durations)
+	if flags.json {
+		summary.Min, summary.Mean, summary.Max, summary.Stddev = min.Seconds(), mean.Seconds(), max.Seconds(), stddev.Seconds()
+		b, _ := json.MarshalIndent(summary, "", "  ")
+		fmt.Println(string(b))
+	} else if len(durations) > 1 {
+		fmt.Printf("%d runs: min %s mean %s max %s stddev %s\n", len(durations),
+			printDuration(min), printDuration(mean), printDuration(max), printDuration(stddev))
+	}
+	return ret
+}
+
+// durationStats returns the minimum, mean, maximum and sample standard
+// deviation of ds
+func durationStats(ds []time.Duration) (min, mean, max, stddev time.Duration) {
+	if len(ds) == 0 {
+		return
+	}
+	min, max = ds[0], ds[0]
+	var sum float64
+	for _, d := range ds {
+		if d < min {
+			min = d
+		}
+		if d > max {
+			max = d
+		}
+		sum += float64(d)
+	}
+	avg := sum / float64(len(ds))
+	mean = time.Duration(avg)
+	if len(ds) > 1 {
+		var sq float64
+		for _, d := range ds {
+			sq += (float64(d) - avg) * (float64(d) - avg)
+		}
+		stddev = time.Duration(math.Sqrt(sq / float64(len(ds)-1)))
+	}
+	return
+}
+
+// startCommand starts the command for t. Its output is printed above the
+// display, and its exit ends the run with its exit code.
+func startCommand(args []string, t *timer) (*exec.Cmd, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdout = w
+	cmd.Stderr = w
+	err = cmd.Start()
+	w.Close()
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+
+	t.output = make(chan string)
+	t.done = make(chan int, 1)
+	go func() {
+		// lines of any length are passed on whole, and a last line without
+		// a newline still gets one
+		br := bufio.NewReader(r)
+		for {
+			line, err := br.ReadString('\n')
+			if line != "" {
+				t.output <- strings.TrimSuffix(line, "\n") + "\n"
+			}
+			if err != nil {
+				break
+			}
+		}
+		r.Close()
+		t.done <- exitCode(cmd.Wait())
+	}()
+	return cmd, nil
+}
+
+// stopCommand interrupts a command that is still running after its timer was
+// quit and returns its exit code once it is gone
+func stopCommand(cmd *exec.Cmd, t *timer) int {
+	cmd.Process.Signal(os.Interrupt)
+	for {
+		select {
+		case s := <-t.output:
+			fmt.Fprint(os.Stderr, s)
+		case code := <-t.done:
+			return code
+		}
+	}
+}
+
+// exitCode turns the result of cmd.Wait into an exit code the way a shell
+// does, 128 plus the signal for a command killed by one
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	if ee, ok := err.(*exec.ExitError); ok {
+		if ws, ok := ee.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+			return 128 + int(ws.Signal())
+		}
+		return ee.ExitCode()
+	}
+	return 1
+}
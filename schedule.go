@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// schedule is a recurring set of wall clock times like "mon-fri 10:00"
+type schedule struct {
+	days  [7]bool // indexed by time.Weekday
+	times []timeOfDay
+	loc   *time.Location
+}
+
+type timeOfDay struct {
+	hour, min, sec int
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// parseSchedule parses a recurrence of the form
+//
+//	[days] times [zone]
+//
+// where days is daily, weekdays, weekends or a comma separated list of day
+// names and ranges (mon-fri,sun), times is a comma separated list of times of
+// day, and zone is a time zone name. Without days the schedule runs daily.
+func parseSchedule(s string, loc *time.Location) (*schedule, error) {
+	sc := &schedule{loc: loc}
+	fields := strings.Fields(s)
+	if len(fields) == 0 || len(fields) > 3 {
+		return nil, fmt.Errorf("invalid schedule %q", s)
+	}
+	if n := len(fields); n > 1 {
+		if zone, err := time.LoadLocation(fields[n-1]); err == nil {
+			sc.loc = zone
+			fields = fields[:n-1]
+		}
+	}
+
+	switch len(fields) {
+	case 1:
+		for i := range sc.days {
+			sc.days[i] = true
+		}
+	case 2:
+		if err := sc.parseDays(strings.ToLower(fields[0])); err != nil {
+			return nil, err
+		}
+		fields = fields[1:]
+	default:
+		return nil, fmt.Errorf("invalid schedule %q", s)
+	}
+
+	for _, t := range strings.Split(fields[0], ",") {
+		tod, err := parseTimeOfDay(t)
+		if err != nil {
+			return nil, err
+		}
+		sc.times = append(sc.times, tod)
+	}
+	sort.Slice(sc.times, func(i, j int) bool {
+		a, b := sc.times[i], sc.times[j]
+		return a.hour*3600+a.min*60+a.sec < b.hour*3600+b.min*60+b.sec
+	})
+	return sc, nil
+}
+
+func (sc *schedule) parseDays(s string) error {
+	switch s {
+	case "daily", "*":
+		s = "sun-sat"
+	case "weekdays":
+		s = "mon-fri"
+	case "weekends":
+		s = "sat,sun"
+	}
+	for _, part := range strings.Split(s, ",") {
+		first, last := part, part
+		if i := strings.IndexByte(part, '-'); i >= 0 {
+			first, last = part[:i], part[i+1:]
+		}
+		from, ok := weekdays[first]
+		if !ok {
+			return fmt.Errorf("unknown day %q", first)
+		}
+		to, ok := weekdays[last]
+		if !ok {
+			return fmt.Errorf("unknown day %q", last)
+		}
+		// ranges may wrap around the end of the week, like fri-mon
+		for d := from; ; d = (d + 1) % 7 {
+			sc.days[d] = true
+			if d == to {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func parseTimeOfDay(s string) (timeOfDay, error) {
+	for _, layout := range alarmLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return timeOfDay{t.Hour(), t.Minute(), t.Second()}, nil
+		}
+	}
+	return timeOfDay{}, fmt.Errorf("invalid time of day %q", s)
+}
+
+// next returns the first time in the schedule after t
+func (sc *schedule) next(t time.Time) time.Time {
+	local := t.In(sc.loc)
+	y, m, d := local.Date()
+	// a week and a day covers every weekday even if today's times are gone
+	for i := 0; i <= 7; i++ {
+		day := time.Date(y, m, d+i, 12, 0, 0, 0, sc.loc)
+		if !sc.days[day.Weekday()] {
+			continue
+		}
+		for _, tod := range sc.times {
+			next := wallClock(y, m, d+i, tod.hour, tod.min, tod.sec, sc.loc)
+			if next.After(t) {
+				return next
+			}
+		}
+	}
+	panic("schedule has no days")
+}
+
+// runSchedule waits for each time in sc and runs a countdown of length when
+// it arrives, or just rings the bell if length is zero, until the user quits
+func runSchedule(ctx context.Context, clock Clock, sc *schedule, length time.Duration, c chan key, e chan int) int {
+	for {
+		now := clock.Now()
+		next := sc.next(now)
+		fmt.Fprintf(os.Stderr, "Next at %s\n", next.Format("Mon Jan 2 15:04:05 MST"))
+		wait := newTimer(clock, ALARM, next.Sub(now))
+		if ret := wait.run(ctx, c, e); ret != 0 || wait.quit {
+			return ret
+		}
+		if length == 0 {
+			continue
+		}
+		t := newTimer(clock, COUNTDOWN, length)
+		if ret := t.run(ctx, c, e); ret != 0 || t.quit {
+			return ret
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+// selftest runs the display loop for a while without a timer behind it and
+// reports how late the ticks came and how long drawing a frame took, for
+// working out why a timer looks off on a busy machine or a slow terminal
+func selftest(args []string) int {
+	fs := flag.NewFlagSet("selftest", flag.ExitOnError)
+	length := fs.Duration("for", 3*time.Second, "run the test for `duration`")
+	displayFlags(fs)
+	fs.Parse(args)
+	if fs.NArg() != 0 || *length <= 0 {
+		fmt.Fprintln(os.Stderr, "Usage: gutimer selftest [-for duration] [display flags]")
+		return 1
+	}
+	if err := checkDisplayFlags(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	interval := refreshInterval()
+	var jitter, late, draw []time.Duration
+	var last time.Time
+	tk := time.NewTicker(interval)
+	start := time.Now()
+	for {
+		at := <-tk.C
+		now := time.Now()
+		if now.Sub(start) > *length {
+			break
+		}
+		// how far apart the ticks were from the interval, and how long
+		// each took to get from the ticker to the loop
+		if !last.IsZero() {
+			jitter = append(jitter, at.Sub(last)-interval)
+		}
+		last = at
+		late = append(late, now.Sub(at))
+		printElapsed(STOPWATCH, 1<<63-1, now.Sub(start))
+		draw = append(draw, time.Since(now))
+	}
+	tk.Stop()
+	screen.finish()
+
+	fmt.Printf("Ticks: %d of %d, every %s\n", len(late), int(*length/interval), interval)
+	for _, r := range []struct {
+		name string
+		ds   []time.Duration
+	}{
+		{"Jitter", jitter},
+		{"Latency", late},
+		{"Draw time", draw},
+	} {
+		min, mean, max, stddev := durationStats(r.ds)
+		fmt.Printf("%s: min %s mean %s max %s stddev %s\n", r.name, min, mean, max, stddev)
+	}
+	return 0
+}
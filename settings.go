@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// configDir is where gutimer keeps its configuration, empty if there is no
+// home directory to put it in
+func configDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "gutimer")
+}
+
+// readSettings reads a file of "key = value" lines and calls set for each.
+// Blank lines and lines starting with # are skipped. Values may be quoted
+// like TOML strings, which is the only way to give one that starts or ends
+// with spaces; unquoted values end at a # after whitespace. Keys after a
+// [table] line are given to set as "table.key".
+func readSettings(path string, set func(key, value string) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	table := ""
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if line[0] == '[' {
+			end := strings.IndexByte(line, ']')
+			if end < 0 || strings.TrimSpace(line[1:end]) == "" {
+				return fmt.Errorf("%s:%d: expected [table]", path, n)
+			}
+			if rest := strings.TrimSpace(line[end+1:]); rest != "" && rest[0] != '#' {
+				return fmt.Errorf("%s:%d: unexpected %q after table", path, n, rest)
+			}
+			table = strings.TrimSpace(line[1:end]) + "."
+			continue
+		}
+		i := strings.IndexByte(line, '=')
+		if i < 0 {
+			return fmt.Errorf("%s:%d: expected key = value", path, n)
+		}
+		key := table + strings.TrimSpace(line[:i])
+		value, err := settingValue(strings.TrimSpace(line[i+1:]))
+		if err == nil {
+			err = set(key, value)
+		}
+		if err != nil {
+			return fmt.Errorf("%s:%d: %v", path, n, err)
+		}
+	}
+	return scanner.Err()
+}
+
+func settingValue(s string) (string, error) {
+	if strings.HasPrefix(s, `"`) {
+		end := strings.LastIndexByte(s, '"')
+		if end == 0 {
+			return "", fmt.Errorf("unterminated string %s", s)
+		}
+		if rest := strings.TrimSpace(s[end+1:]); rest != "" && rest[0] != '#' {
+			return "", fmt.Errorf("unexpected %q after string", rest)
+		}
+		return strconv.Unquote(s[:end+1])
+	}
+	for i := 1; i < len(s); i++ {
+		if s[i] == '#' && (s[i-1] == ' ' || s[i-1] == '\t') {
+			return strings.TrimSpace(s[:i]), nil
+		}
+	}
+	return s, nil
+}
+
+// configFile is the user's configuration, which gives defaults for flags
+func configFile() string {
+	return filepath.Join(configDir(), "config.toml")
+}
+
+// projectName is the file a directory can keep its own configuration in
+const projectName = ".gutimer.toml"
+
+// projectFile is the nearest .gutimer.toml in the working directory or
+// above it, empty if there is none
+func projectFile() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	for {
+		path := filepath.Join(dir, projectName)
+		if fi, err := os.Stat(path); err == nil && fi.Mode().IsRegular() {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// projectUnsafe are the flags a .gutimer.toml cannot set, in its settings
+// or its presets, since they run commands, write files or listen on the
+// network and whoever made the directory need not be the one running
+// gutimer in it. -warn can run commands with run:.
+var projectUnsafe = map[string]bool{
+	"dnd-hook": true, "on-finish": true, "record": true, "events": true,
+	"text-file": true, "serve": true, "udp-listen": true, "warn": true,
+}
+
+// isolated leaves out the config files and environment variables, for
+// gutimer simulate, whose scripts must run the same wherever they are run
+var isolated bool
+
+// configFiles are the files loadConfig reads, later ones over earlier ones
+func configFiles() []string {
+	if isolated {
+		return nil
+	}
+	files := []string{configFile()}
+	if project := projectFile(); project != "" {
+		files = append(files, project)
+	}
+	return files
+}
+
+// defaultModes are the modes a [defaults] table can give an argument to
+var defaultModes = map[string]bool{"timer": true, "countdown": true, "alarm": true}
+
+// modeDefault is the argument mode takes when the command line has none,
+// from the [defaults] table of the config files, empty if there is none:
+//
+//	[defaults]
+//	countdown = "5m"
+//	alarm = "7:00"
+func modeDefault(mode Mode) (string, error) {
+	value := ""
+	for _, path := range configFiles() {
+		err := readSettings(path, func(key, v string) error {
+			if key == "defaults."+mode.String() {
+				value = v
+			}
+			return nil
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+	return value, nil
+}
+
+// loadConfig sets flags in fs from the config file before the command line
+// is parsed, so the command line still has the last word. Keys are flag
+// names without the dash:
+//
+//	# longer pomodoros
+//	work = "50m"
+//	short = "10m"
+//	bar = true
+//
+// A .gutimer.toml in the working directory or above it is read after the
+// user's config and wins over it, like the presets in its [presets] table
+// win over the user's, and the same goes for its [defaults], see
+// modeDefault. Then GUTIMER_ environment variables win over both,
+// see loadEnv. A missing config file is not an error. Subcommands only have
+// some of the flags, so unless strict is set keys that are not flags of fs
+// are skipped.
+func loadConfig(fs *flag.FlagSet, strict bool) error {
+	for i, path := range configFiles() {
+		project := i > 0
+		err := readSettings(path, func(key, value string) error {
+			if strings.HasPrefix(key, "presets.") {
+				return nil
+			}
+			if mode := strings.TrimPrefix(key, "defaults."); mode != key {
+				if strict && !defaultModes[mode] {
+					return fmt.Errorf("no default can be given to %q", mode)
+				}
+				return nil
+			}
+			if fs.Lookup(key) == nil {
+				if !strict {
+					return nil
+				}
+				return fmt.Errorf("unknown setting %q", key)
+			}
+			if project && projectUnsafe[key] {
+				return fmt.Errorf("%s cannot be set in %s", key, projectName)
+			}
+			return fs.Set(key, value)
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return loadEnv(fs)
+}
+
+// envName is the environment variable for a flag: GUTIMER_REFRESH for
+// -refresh, GUTIMER_ON_FINISH for -on-finish
+func envName(flag string) string {
+	return "GUTIMER_" + strings.ToUpper(strings.ReplaceAll(flag, "-", "_"))
+}
+
+// loadEnv sets the flags of fs that have an environment variable set, for
+// containers and scripts where a config file is a bother. Other GUTIMER_
+// variables, like those hooks are run with, are left alone.
+func loadEnv(fs *flag.FlagSet) error {
+	if isolated {
+		return nil
+	}
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		value, ok := os.LookupEnv(envName(f.Name))
+		if !ok || err != nil {
+			return
+		}
+		if e := fs.Set(f.Name, value); e != nil {
+			err = fmt.Errorf("%s: %v", envName(f.Name), e)
+		}
+	})
+	return err
+}
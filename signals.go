@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// signalCommands are what the signals a timer handles do, for controlling
+// one that has no terminal to read keys from
+var signalCommands = map[os.Signal]string{
+	syscall.SIGUSR1: "toggle",
+	syscall.SIGUSR2: "lap",
+}
+
+// detachCode is sent on e for SIGQUIT, which C-\ sends: a single timer is
+// detached, and anything else ends like it does for C-c
+const detachCode = -1
+
+// handleSignals ends the run cleanly on C-c, SIGTERM, SIGHUP or SIGQUIT, by
+// sending on e like C-d does, so the terminal is restored and what is saved
+// on exit still is, until ctx is cancelled. It is started before any mode
+// runs, so every one of them gets the signals on the e it reads. gutimer run
+// leaves C-c to the command it runs, and locked keys leave C-c and C-\ be.
+func handleSignals(ctx context.Context, e chan<- int) {
+	stop := []os.Signal{syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT}
+	if flags.command == nil {
+		stop = append(stop, os.Interrupt)
+	}
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, stop...)
+	defer signal.Stop(sig)
+	for {
+		select {
+		case s := <-sig:
+			switch {
+			case (s == os.Interrupt || s == syscall.SIGQUIT) && keys.isLocked():
+			case s == syscall.SIGQUIT:
+				send(ctx, e, detachCode)
+			default:
+				send(ctx, e, 1)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// handleCommandSignals runs the command of each signal in signalCommands
+// on t until ctx is cancelled
+func handleCommandSignals(ctx context.Context, t *timer) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGUSR1, syscall.SIGUSR2)
+	defer signal.Stop(sig)
+	for {
+		select {
+		case s := <-sig:
+			select {
+			case t.commands <- signalCommands[s]:
+			case <-ctx.Done():
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
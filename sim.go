@@ -0,0 +1,309 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fakeClock is a Clock that only moves when told to, ticking its tickers
+// on the way, for gutimer simulate
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*fakeTicker
+}
+
+type fakeTicker struct {
+	clock   *fakeClock
+	c       chan time.Time
+	every   time.Duration
+	next    time.Time
+	stopped bool
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *fakeClock) NewTicker(d time.Duration) Ticker {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t := &fakeTicker{clock: f, c: make(chan time.Time), every: d, next: f.now.Add(d)}
+	f.tickers = append(f.tickers, t)
+	return t
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTicker) Stop() {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	t.stopped = true
+}
+
+// advance moves the clock on by d, stopping at every tick due on the way
+// to hand it to deliver, which reports false once nothing takes ticks
+func (f *fakeClock) advance(d time.Duration, deliver func(c chan time.Time, at time.Time) bool) {
+	f.mu.Lock()
+	end := f.now.Add(d)
+	for {
+		var due *fakeTicker
+		for _, t := range f.tickers {
+			if !t.stopped && !t.next.After(end) && (due == nil || t.next.Before(due.next)) {
+				due = t
+			}
+		}
+		if due == nil {
+			break
+		}
+		f.now = due.next
+		due.next = due.next.Add(due.every)
+		// the run loop reads the clock as it handles the tick
+		f.mu.Unlock()
+		ok := deliver(due.c, f.now)
+		f.mu.Lock()
+		if !ok {
+			break
+		}
+	}
+	f.now = end
+	f.mu.Unlock()
+}
+
+// simulation is a timer run by a script instead of a terminal and the
+// wall clock
+type simulation struct {
+	clock *fakeClock
+	t     *timer
+	c     chan key
+	e     chan int
+	ctx   context.Context
+	ended chan struct{} // closed once the run is over
+	code  int           // what the run returned
+}
+
+// simulate runs the script read from r, which drives a timer with a fake
+// clock and scripted keys and checks what it draws. Scripts are line based
+// and blank lines and lines starting with # are skipped:
+//
+//	args -c 10s     the command line, before anything else
+//	advance 3s      move the clock on, ticking the display as it goes
+//	keys " "        type the keys, quoted like a Go string or as they are,
+//	                with escape sequences for keys like "\x1b[A" for up
+//	expect [00:07   the last frame drawn has the text in it
+//	exit 0          the run is over, with the exit code
+//
+// The history and other files go to a directory that is thrown away, and
+// the config files, GUTIMER_ variables, colors and the battery are left
+// out, so a script always draws the same.
+// It returns the first thing that isn't as the script expects.
+func simulate(r io.Reader, name string) error {
+	sc := bufio.NewScanner(r)
+	var sim *simulation
+	defer func() {
+		if sim != nil {
+			sim.stop()
+		}
+	}()
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		verb, arg := line, ""
+		if i := strings.IndexAny(line, " \t"); i >= 0 {
+			verb, arg = line[:i], strings.TrimSpace(line[i+1:])
+		}
+		var err error
+		switch {
+		case verb == "args" && sim == nil:
+			sim, err = startSimulation(arg)
+		case sim == nil:
+			err = fmt.Errorf("%s before args", verb)
+		case verb == "advance":
+			var d time.Duration
+			if d, err = parseDuration(arg); err == nil {
+				sim.advance(d)
+			}
+		case verb == "keys":
+			var keys string
+			if keys, err = scriptString(arg); err == nil {
+				sim.keys(keys)
+			}
+		case verb == "expect":
+			var want string
+			if want, err = scriptString(arg); err == nil {
+				if got := strings.Join(screen.last, "\n"); !strings.Contains(got, want) {
+					err = fmt.Errorf("expected %q in the frame:\n%s", want, got)
+				}
+			}
+		case verb == "exit":
+			var code int
+			if code, err = strconv.Atoi(arg); err == nil {
+				select {
+				case <-sim.ended:
+					if sim.code != code {
+						err = fmt.Errorf("exited with %d, not %d", sim.code, code)
+					}
+				default:
+					err = fmt.Errorf("still running, expected exit %d", code)
+				}
+			}
+		default:
+			err = fmt.Errorf("unknown step %q", verb)
+		}
+		if err != nil {
+			return fmt.Errorf("%s:%d: %v", name, n, err)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	if sim == nil {
+		return fmt.Errorf("%s: no args", name)
+	}
+	return nil
+}
+
+// scriptString is s unquoted if it is in double quotes, or as it is
+func scriptString(s string) (string, error) {
+	if strings.HasPrefix(s, `"`) {
+		return strconv.Unquote(s)
+	}
+	return s, nil
+}
+
+// startSimulation parses the command line of a script and starts its timer
+// running on a fake clock
+func startSimulation(args string) (*simulation, error) {
+	words, err := splitWords(args)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := ioutil.TempDir("", "gutimer-simulate")
+	if err != nil {
+		return nil, err
+	}
+	os.Setenv("XDG_DATA_HOME", dir)
+	os.Setenv("XDG_CONFIG_HOME", dir)
+	isolated = true
+
+	// before the flags, so a missing duration is never picked on the
+	// terminal the script was run from
+	screen = &layout{w: ioutil.Discard}
+	flags = Flags{}
+	flag.CommandLine = flag.NewFlagSet("gutimer", flag.ContinueOnError)
+	flag.CommandLine.SetOutput(ioutil.Discard)
+	os.Args = append([]string{"gutimer", "-color", "never", "-battery-saver=false"}, words...)
+	mode, duration, err := parseArgs()
+	switch {
+	case err != nil:
+	case mode != TIMER && mode != COUNTDOWN && mode != STOPWATCH && mode != ALARM,
+		flags.after != 0, flags.watchPid != 0, flags.stopWhen != "", flags.name != "":
+		err = fmt.Errorf("only a single timer can be simulated")
+	}
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	clock := &fakeClock{now: time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC)}
+	sim := &simulation{clock: clock, c: make(chan key), e: make(chan int), ctx: ctx, ended: make(chan struct{})}
+	sim.t = newTimer(clock, mode, duration)
+	sim.t.offset = flags.offset
+	sim.t.waiting = flags.startPaused || flags.wait
+	go func() {
+		sim.code = sim.t.run(ctx, sim.c, sim.e)
+		os.RemoveAll(dir)
+		// ended first, so whatever gives up on the run when ctx is done
+		// finds it over
+		close(sim.ended)
+		cancel()
+	}()
+	sim.sync()
+	return sim, nil
+}
+
+// sync waits until the run loop has dealt with everything sent to it, by
+// asking for its status, which it only answers in between
+func (s *simulation) sync() {
+	select {
+	case <-s.ended:
+	default:
+		s.t.query(s.ctx)
+	}
+}
+
+func (s *simulation) advance(d time.Duration) {
+	s.clock.advance(d, func(c chan time.Time, at time.Time) bool {
+		select {
+		case c <- at:
+			// the loop reads the clock as it handles the tick, so it
+			// has to be done before the clock moves on
+			s.sync()
+			return true
+		case <-s.ended:
+			return false
+		}
+	})
+	s.sync()
+}
+
+// keys types keys, which go through the decoder like those read from a
+// terminal, so escape sequences and pastes can be given too
+func (s *simulation) keys(keys string) {
+	var d decoder
+	for _, k := range d.decode([]byte(keys)) {
+		select {
+		case s.c <- k:
+		case <-s.ended:
+			return
+		}
+		s.sync()
+	}
+}
+
+// stop ends a run the script left going
+func (s *simulation) stop() {
+	select {
+	case s.e <- 0:
+	case <-s.ended:
+	}
+	<-s.ended
+}
+
+// simulateCommand runs `gutimer simulate script...`, each script in turn
+func simulateCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: gutimer simulate script...")
+		return 1
+	}
+	for _, path := range args {
+		f, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to read script: %v\n", err)
+			return 1
+		}
+		err = simulate(f, path)
+		f.Close()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	return 0
+}
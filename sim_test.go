@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestSimulate runs the gutimer simulate scripts in testdata
+func TestSimulate(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "*.sim"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no scripts in testdata")
+	}
+	for _, path := range paths {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			f, err := os.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			if err := simulate(f, path); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// TestSimulateErrors checks that a script with a command line gutimer
+// would refuse fails with why, rather than exiting
+func TestSimulateErrors(t *testing.T) {
+	for _, script := range []string{
+		"args -c nonsense\n",
+		"args -no-such-flag -c 1m\n",
+		"args -s -warn 1m\n",
+		"args -p\n",
+		"advance 1s\n",
+	} {
+		if err := simulate(strings.NewReader(script), "script"); err == nil {
+			t.Errorf("%q: no error", script)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// stopWhen sends 0 on e when path appears or changes, which stops the
+// timer. inotify watches the directory the path is in, since it need not
+// be there yet, or the path itself if it is a directory, where anything
+// changing in it counts. Files count once written and closed, or renamed
+// into place, rather than as soon as something starts writing them.
+func stopWhen(ctx context.Context, path string, e chan<- int) error {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return err
+	}
+	dir, name := path, ""
+	var mask uint32 = unix.IN_CREATE | unix.IN_DELETE | unix.IN_CLOSE_WRITE | unix.IN_MOVED_TO | unix.IN_MOVED_FROM | unix.IN_ATTRIB
+	if fi, err := os.Stat(path); err != nil || !fi.IsDir() {
+		dir, name = filepath.Dir(path), filepath.Base(path)
+		mask = unix.IN_CREATE | unix.IN_CLOSE_WRITE | unix.IN_MOVED_TO | unix.IN_ATTRIB
+	}
+	if _, err := unix.InotifyAddWatch(fd, dir, mask); err != nil {
+		unix.Close(fd)
+		return fmt.Errorf("%s: %v", dir, err)
+	}
+
+	// a non-blocking file goes through the poller, so closing it ends a
+	// read that is waiting
+	f := os.NewFile(uintptr(fd), "inotify")
+	go func() {
+		<-ctx.Done()
+		f.Close()
+	}()
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, err := f.Read(buf)
+			if err != nil {
+				return
+			}
+			for off := 0; off+unix.SizeofInotifyEvent <= n; {
+				ev := (*unix.InotifyEvent)(unsafe.Pointer(&buf[off]))
+				start := off + unix.SizeofInotifyEvent
+				off = start + int(ev.Len)
+				if off > n {
+					break
+				}
+				// a new file is waited on until it is written, but a new
+				// directory is there at once
+				if ev.Mask&unix.IN_CREATE != 0 && ev.Mask&unix.IN_ISDIR == 0 && name != "" {
+					continue
+				}
+				if name == "" || string(bytes.TrimRight(buf[start:off], "\x00")) == name {
+					send(ctx, e, 0)
+					return
+				}
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,37 @@
+//go:build !linux
+// +build !linux
+
+package main
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// stopWhen sends 0 on e when path appears or changes, which stops the
+// timer. Without inotify it is checked on every watchInterval.
+func stopWhen(ctx context.Context, path string, e chan<- int) error {
+	last, lastErr := os.Stat(path)
+	go func() {
+		tk := time.NewTicker(watchInterval)
+		defer tk.Stop()
+		for {
+			select {
+			case <-tk.C:
+			case <-ctx.Done():
+				return
+			}
+			fi, err := os.Stat(path)
+			switch {
+			case err != nil && lastErr != nil:
+				continue
+			case err != nil || lastErr != nil,
+				fi.ModTime() != last.ModTime(), fi.Size() != last.Size():
+				send(ctx, e, 0)
+				return
+			}
+		}
+	}()
+	return nil
+}
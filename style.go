@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// a renderer draws the time of the display in large characters, one string
+// per line, all the same width
+type renderer interface {
+	render(s string) []string
+}
+
+// styles are the renderers -style can pick, made for a digit -size
+var styles = map[string]func(size int) renderer{
+	"seven": newSevenSegment,
+}
+
+// segments lit for each character, bit 0 is the top segment a, then
+// clockwise b to f, and g in the middle
+var segments = map[rune]uint8{
+	'0': 0x3f, '1': 0x06, '2': 0x5b, '3': 0x4f, '4': 0x66,
+	'5': 0x6d, '6': 0x7d, '7': 0x07, '8': 0x7f, '9': 0x6f,
+	'd': 0x5e, '-': 0x40,
+}
+
+const (
+	segA = 1 << iota
+	segB
+	segC
+	segD
+	segE
+	segF
+	segG
+)
+
+// sevenSegment draws digits like a seven segment display made of blocks.
+// Every segment is size blocks long.
+type sevenSegment struct {
+	size int
+}
+
+func newSevenSegment(size int) renderer {
+	return sevenSegment{size}
+}
+
+func (r sevenSegment) render(s string) []string {
+	lines := make([]string, 2*r.size+3)
+	first := true
+	for _, c := range s {
+		if !first {
+			pad(lines, 1)
+		}
+		first = false
+		switch c {
+		case ':':
+			r.colon(lines)
+		case '.':
+			pad(lines[:len(lines)-1], 1)
+			lines[len(lines)-1] += "█"
+		default:
+			seg, ok := segments[c]
+			if !ok {
+				pad(lines, 1)
+				continue
+			}
+			r.digit(lines, seg)
+		}
+	}
+	return lines
+}
+
+// digit appends one character made of the segments in seg
+func (r sevenSegment) digit(lines []string, seg uint8) {
+	lit := func(mask uint8) bool { return seg&mask != 0 }
+	mid := r.size + 1
+	for y := range lines {
+		var b strings.Builder
+		for x := 0; x < r.size+2; x++ {
+			left, right := x == 0, x == r.size+1
+			on := false
+			switch {
+			case y == 0:
+				on = lit(segA) || left && lit(segF) || right && lit(segB)
+			case y == mid:
+				on = lit(segG) || left && lit(segF|segE) || right && lit(segB|segC)
+			case y == len(lines)-1:
+				on = lit(segD) || left && lit(segE) || right && lit(segC)
+			case y < mid:
+				on = left && lit(segF) || right && lit(segB)
+			default:
+				on = left && lit(segE) || right && lit(segC)
+			}
+			if on {
+				b.WriteString("█")
+			} else {
+				b.WriteString(" ")
+			}
+		}
+		lines[y] += b.String()
+	}
+}
+
+// colon appends a dot in the middle of each half of the digit height
+func (r sevenSegment) colon(lines []string) {
+	top := (r.size + 1) / 2
+	bottom := r.size + 1 + (r.size+2)/2
+	for y := range lines {
+		if y == top || y == bottom {
+			lines[y] += "█"
+		} else {
+			lines[y] += " "
+		}
+	}
+}
+
+// pad appends n blank columns to every line
+func pad(lines []string, n int) {
+	for i := range lines {
+		lines[i] += strings.Repeat(" ", n)
+	}
+}
+
+// bigFrame draws the time in the -style renderer, under a line with the
+// label and percentage if there are any. It returns nil if the digits are
+// too wide for the terminal.
+func bigFrame(mode Mode, total, duration time.Duration) []string {
+	var lines []string
+	header := flags.label
+	if flags.percent && mode != STOPWATCH {
+		header = strings.TrimSpace(fmt.Sprintf("%s %d%%", header, newDisplay(mode, total, duration).Percent))
+	}
+	if header != "" {
+		lines = append(lines, header)
+	}
+	d := duration
+	if mode != STOPWATCH {
+		d = total - duration
+	}
+	digits := flags.style.render(strings.Trim(printDuration(d), "[]"))
+	if c := screen.columns(); c > 0 && utf8.RuneCountInString(digits[0]) > c {
+		return nil
+	}
+	for i := range digits {
+		digits[i] = color(stateColor(mode, total, duration), digits[i])
+	}
+	return append(lines, digits...)
+}
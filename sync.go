@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The sync protocol is line based. A joining instance sends
+//
+//	sync <id>
+//
+// and the authoritative instance answers with its state at the moment it
+// handled the request
+//
+//	state <id> <mode> <duration> <elapsed> <paused>
+//
+// with durations in nanoseconds and paused as 0 or 1. The id is echoed back
+// so the client can match replies to requests and measure the round trip.
+
+const syncInterval = time.Second
+
+// serveSync accepts joining instances on addr until ctx is cancelled and
+// answers their sync requests from t
+func serveSync(ctx context.Context, addr string, t *timer) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveSyncConn(ctx, conn, t)
+		}
+	}()
+	return nil
+}
+
+func serveSyncConn(ctx context.Context, conn net.Conn, t *timer) {
+	defer conn.Close()
+	sc := bufio.NewScanner(conn)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) != 2 || fields[0] != "sync" {
+			fmt.Fprintf(conn, "error unknown request\n")
+			continue
+		}
+		st, ok := t.query(ctx)
+		if !ok {
+			return
+		}
+		paused := 0
+		if st.Paused {
+			paused = 1
+		}
+		_, err := fmt.Fprintf(conn, "state %s %v %d %d %d\n", fields[1], st.Mode, st.Duration, st.Elapsed, paused)
+		if err != nil {
+			return
+		}
+	}
+}
+
+// syncReply is a parsed state line
+type syncReply struct {
+	id int64
+	status
+}
+
+func parseSyncReply(line string) (syncReply, error) {
+	var r syncReply
+	fields := strings.Fields(line)
+	if len(fields) != 6 || fields[0] != "state" {
+		return r, fmt.Errorf("unexpected reply %q", line)
+	}
+	var err error
+	var nums [4]int64
+	for i, f := range []string{fields[1], fields[3], fields[4], fields[5]} {
+		nums[i], err = strconv.ParseInt(f, 10, 64)
+		if err != nil {
+			return r, fmt.Errorf("unexpected reply %q", line)
+		}
+	}
+	r.Mode, err = parseMode(fields[2])
+	if err != nil {
+		return r, err
+	}
+	r.id = nums[0]
+	r.Duration = time.Duration(nums[1])
+	r.Elapsed = time.Duration(nums[2])
+	r.Paused = nums[3] != 0
+	return r, nil
+}
+
+// join displays a timer served by another instance. The local display runs
+// off the local clock and is corrected after every sync: half the round trip
+// is added to the remote elapsed time to account for the reply being in
+// flight, and small differences are slewed in gradually so the display does
+// not visibly jump.
+func join(args []string) int {
+	fs := flag.NewFlagSet("join", flag.ExitOnError)
+	displayFlags(fs)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: gutimer join [display flags] host:port")
+		return 1
+	}
+	if err := checkDisplayFlags(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	conn, err := net.Dial("tcp", fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to join timer: %v\n", err)
+		return 1
+	}
+	defer conn.Close()
+
+	clock := realClock{}
+	epoch := clock.Now()
+	replies := make(chan syncReply)
+	errs := make(chan error, 1)
+	go func() {
+		sc := bufio.NewScanner(conn)
+		for sc.Scan() {
+			r, err := parseSyncReply(sc.Text())
+			if err != nil {
+				errs <- err
+				return
+			}
+			replies <- r
+		}
+		if err := sc.Err(); err != nil {
+			errs <- err
+			return
+		}
+		errs <- fmt.Errorf("connection closed")
+	}()
+
+	sendSync := func() error {
+		_, err := fmt.Fprintf(conn, "sync %d\n", clock.Now().Sub(epoch))
+		return err
+	}
+	if err := sendSync(); err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to join timer: %v\n", err)
+		return 1
+	}
+
+	var st status
+	var start time.Time
+	synced := false
+	tk := clock.NewTicker(refreshInterval())
+	defer tk.Stop()
+	sk := clock.NewTicker(syncInterval)
+	defer sk.Stop()
+	for {
+		select {
+		case now := <-replies:
+			received := clock.Now()
+			rtt := received.Sub(epoch) - time.Duration(now.id)
+			remote := now.Elapsed
+			if !now.Paused {
+				remote += rtt / 2
+			}
+			target := received.Add(-remote)
+			if synced && !st.Paused && !now.Paused {
+				// slew by half the error unless it is too large to hide
+				if drift := start.Sub(target); drift > -100*time.Millisecond && drift < 100*time.Millisecond {
+					target = target.Add(drift / 2)
+				}
+			}
+			start = target
+			st = now.status
+			st.Elapsed = remote
+			synced = true
+		case <-tk.C():
+			if !synced {
+				continue
+			}
+			elapsed := st.Elapsed
+			if !st.Paused {
+				elapsed = clock.Now().Sub(start)
+			}
+			if elapsed >= st.Duration {
+				screen.bell()
+				printElapsed(st.Mode, st.Duration, st.Duration)
+				screen.finish()
+				return 0
+			}
+			printElapsed(st.Mode, st.Duration, elapsed)
+		case <-sk.C():
+			if err := sendSync(); err != nil {
+				screen.finish()
+				fmt.Fprintf(os.Stderr, "Lost connection to timer: %v\n", err)
+				return 1
+			}
+		case err := <-errs:
+			// the serving instance exits as soon as it finishes, which
+			// can be a little before we get there ourselves
+			if synced && !st.Paused && clock.Now().Sub(start)+syncInterval >= st.Duration {
+				screen.bell()
+				printElapsed(st.Mode, st.Duration, st.Duration)
+				screen.finish()
+				return 0
+			}
+			screen.finish()
+			fmt.Fprintf(os.Stderr, "Lost connection to timer: %v\n", err)
+			return 1
+		}
+	}
+}
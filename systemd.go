@@ -0,0 +1,133 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// systemdExport writes a .timer/.service pair that runs gutimer at the times
+// given by an alarm or recurring spec, so reminders keep working without a
+// terminal or a login session
+func systemdExport(args []string) int {
+	fs := flag.NewFlagSet("systemd-export", flag.ExitOnError)
+	name := fs.String("name", "reminder", "unit `name`, written as gutimer-name.timer and .service")
+	dir := fs.String("dir", ".", "`directory` to write the units to")
+	alarm := fs.Bool("a", false, "go off once at the alarm time given as argument")
+	every := fs.String("every", "", "go off at every time in `schedule`")
+	length := fs.String("for", "0", "length of the countdown to run each time")
+	fs.Parse(args)
+
+	if *alarm == (*every != "") {
+		fmt.Fprintln(os.Stderr, "Usage: gutimer systemd-export [-name name] [-dir dir] (-a time | -every schedule) [-for duration]")
+		return 1
+	}
+	duration, err := parseDuration(*length)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Parse error: %v\n", err)
+		return 1
+	}
+
+	var calendar []string
+	if *alarm {
+		calendar, err = alarmCalendar(fs.Arg(0), time.Now())
+	} else {
+		var sc *schedule
+		sc, err = parseSchedule(*every, time.Local)
+		if err == nil {
+			calendar = sc.calendar()
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Parse error: %v\n", err)
+		return 1
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to find gutimer executable: %v\n", err)
+		return 1
+	}
+
+	unit := "gutimer-" + *name
+	var timer strings.Builder
+	fmt.Fprintf(&timer, "[Unit]\nDescription=gutimer %s\n\n[Timer]\n", *name)
+	for _, c := range calendar {
+		fmt.Fprintf(&timer, "OnCalendar=%s\n", c)
+	}
+	fmt.Fprintf(&timer, "\n[Install]\nWantedBy=timers.target\n")
+
+	// nobody watches a unit run, so the end is shown as a notification,
+	// and -on-finish from the config is done as well
+	service := fmt.Sprintf("[Unit]\nDescription=gutimer %s\n\n[Service]\nType=oneshot\nExecStart=%s -batch -notify -label %s -c %v\n",
+		*name, systemdQuote(exe), systemdQuote(*name), duration)
+
+	for _, f := range []struct{ path, content string }{
+		{filepath.Join(*dir, unit+".timer"), timer.String()},
+		{filepath.Join(*dir, unit+".service"), service},
+	} {
+		if err := ioutil.WriteFile(f.path, []byte(f.content), 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to write unit: %v\n", err)
+			return 1
+		}
+		fmt.Printf("Wrote %s\n", f.path)
+	}
+	fmt.Printf("Copy them to ~/.config/systemd/user and run: systemctl --user enable --now %s.timer\n", unit)
+	return 0
+}
+
+// alarmCalendar converts an alarm target into systemd OnCalendar values
+func alarmCalendar(arg string, now time.Time) ([]string, error) {
+	spec, err := parseAlarmSpec(arg, now)
+	if err != nil {
+		return nil, err
+	}
+	if spec.daily == nil {
+		return []string{spec.at.UTC().Format("2006-01-02 15:04:05") + " UTC"}, nil
+	}
+	tod := spec.daily
+	c := fmt.Sprintf("*-*-* %02d:%02d:%02d", tod.hour, tod.min, tod.sec)
+	if spec.loc != time.Local {
+		c += " " + spec.loc.String()
+	}
+	return []string{c}, nil
+}
+
+var systemdDays = []string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"}
+
+// calendar converts the schedule into systemd OnCalendar values, one per
+// time of day
+func (sc *schedule) calendar() []string {
+	var days []string
+	for d, on := range sc.days {
+		if on {
+			days = append(days, systemdDays[d])
+		}
+	}
+	prefix := ""
+	if len(days) < 7 {
+		prefix = strings.Join(days, ",") + " "
+	}
+	zone := ""
+	if sc.loc != time.Local {
+		zone = " " + sc.loc.String()
+	}
+
+	var specs []string
+	for _, tod := range sc.times {
+		specs = append(specs, fmt.Sprintf("%s*-*-* %02d:%02d:%02d%s", prefix, tod.hour, tod.min, tod.sec, zone))
+	}
+	return specs
+}
+
+// systemdQuote quotes a path for an ExecStart line if it needs it
+func systemdQuote(s string) string {
+	if !strings.ContainsAny(s, " \t\"'\\") {
+		return s
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
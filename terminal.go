@@ -0,0 +1,107 @@
+package main
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+	"golang.org/x/term"
+)
+
+// terminal is the controlling terminal, in cbreak mode while a timer reads
+// keys from it
+type terminal struct {
+	f     *os.File
+	saved *term.State
+}
+
+// ttyFile opens the terminal keys are read from: a copy of stdin when it is
+// one, and /dev/tty only when stdin is redirected. On macOS /dev/tty can't
+// be waited on with kqueue, so reading it behaves worse than reading the
+// terminal device stdin is already open on.
+func ttyFile() (*os.File, error) {
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		fd, err := unix.Dup(int(os.Stdin.Fd()))
+		if err != nil {
+			return nil, err
+		}
+		return os.NewFile(uintptr(fd), "/dev/stdin"), nil
+	}
+	return os.OpenFile("/dev/tty", os.O_RDWR, 0)
+}
+
+// openTerminal opens the controlling terminal and puts it in cbreak mode:
+// keys arrive as they are typed and are not echoed, but C-c still sends a
+// signal and output is still processed, unlike in raw mode
+func openTerminal() (*terminal, error) {
+	f, err := ttyFile()
+	if err != nil {
+		return nil, err
+	}
+	saved, err := term.GetState(int(f.Fd()))
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	t := &terminal{f: f, saved: saved}
+	if err := cbreak(int(f.Fd())); err != nil {
+		t.restore()
+		return nil, err
+	}
+	return t, nil
+}
+
+// restore puts the terminal back the way it was found
+func (t *terminal) restore() error {
+	err := term.Restore(int(t.f.Fd()), t.saved)
+	t.f.Close()
+	return err
+}
+
+// askKeys reads keys from the terminal and hands them to press until it
+// reports it is done, or q, escape, C-c or C-d are pressed, for a question
+// asked before the timer starts. C-c comes as a key rather than a signal,
+// so it is never left to kill gutimer with the terminal in cbreak mode.
+// The question is cleared from the screen after.
+func askKeys(press func(k key) bool) error {
+	f, err := ttyFile()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	saved, err := term.GetState(int(f.Fd()))
+	if err != nil {
+		return err
+	}
+	defer term.Restore(int(f.Fd()), saved)
+	if err := keysOnly(int(f.Fd())); err != nil {
+		return err
+	}
+	defer screen.clear()
+
+	buf := make([]byte, 64)
+	var d decoder
+	for {
+		n, err := f.Read(buf)
+		for _, k := range d.decode(buf[:n]) {
+			if k.name == "" && (k.char == 'q' || k.char == escape || k.char == '\x03' || k.char == '\x04') {
+				return nil
+			}
+			if press(k) {
+				return nil
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// terminalSize returns the size of the terminal on fd, or zeros if fd is not
+// a terminal
+func terminalSize(fd uintptr) (cols, rows int) {
+	cols, rows, err := term.GetSize(int(fd))
+	if err != nil {
+		return 0, 0
+	}
+	return cols, rows
+}
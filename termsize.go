@@ -0,0 +1,38 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+)
+
+var (
+	watchOnce sync.Once
+	cols      int32
+)
+
+// columns returns the width of the terminal the layout draws on, 0 if it is
+// not a terminal. The first call starts following window resizes.
+func (l *layout) columns() int {
+	f, ok := l.w.(*os.File)
+	if !ok {
+		return 0
+	}
+	watchOnce.Do(func() {
+		update := func() {
+			c, _ := terminalSize(f.Fd())
+			atomic.StoreInt32(&cols, int32(c))
+		}
+		update()
+		winch := make(chan os.Signal, 1)
+		signal.Notify(winch, syscall.SIGWINCH)
+		go func() {
+			for range winch {
+				update()
+			}
+		}()
+	})
+	return int(atomic.LoadInt32(&cols))
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// textFile keeps the time written to a file for -text-file, which OBS and
+// the like can show with a text source reading from a file
+type textFile struct {
+	path string
+	last string
+}
+
+// write puts the time left of a countdown or the time on a stopwatch in
+// the file, after the -label if there is one. The file is only rewritten
+// when the text changes, and by renaming a new file over it so it is never
+// read half written.
+func (f *textFile) write(mode Mode, total time.Duration, elapsed time.Duration) {
+	d := elapsed
+	if mode != STOPWATCH {
+		d = total - elapsed
+	}
+	sign := ""
+	if d < 0 {
+		sign, d = "-", -d
+	}
+	s := sign + shortDuration(d)
+	if flags.label != "" {
+		s = flags.label + " " + s
+	}
+	if s == f.last {
+		return
+	}
+	tmp := f.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(s+"\n"), 0644); err != nil {
+		return
+	}
+	if os.Rename(tmp, f.path) == nil {
+		f.last = s
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// theme is how the display looks. Colors are SGR parameters, empty for the
+// terminal's own color.
+type theme struct {
+	running  string // a countdown or stopwatch that is going
+	overtime string // a countdown past its end
+	done     string // a countdown that has finished
+	barFull  string
+	barEmpty string
+	gradient bool   // color the bar from green to red as it fills
+	style    string // -style to use when none is given
+	size     int
+}
+
+var currentTheme = theme{
+	overtime: red,
+	barFull:  "█",
+	barEmpty: "░",
+}
+
+var colorNames = map[string]string{
+	"black":   "30",
+	"red":     "31",
+	"green":   "32",
+	"yellow":  "33",
+	"blue":    "34",
+	"magenta": "35",
+	"cyan":    "36",
+	"white":   "37",
+}
+
+// parseColor takes a color name, optionally prefixed with "bright" or
+// "bold", a #rrggbb color or raw SGR parameters like 1;31
+func parseColor(s string) (string, error) {
+	if s == "" || s == "default" {
+		return "", nil
+	}
+	if s[0] == '#' {
+		return parseHexColor(s)
+	}
+	words := strings.Fields(strings.ToLower(s))
+	if c, ok := colorNames[words[len(words)-1]]; ok && len(words) <= 2 {
+		if len(words) == 1 {
+			return c, nil
+		}
+		switch words[0] {
+		case "bright":
+			n, _ := strconv.Atoi(c)
+			return strconv.Itoa(n + 60), nil
+		case "bold":
+			return "1;" + c, nil
+		}
+	}
+	for _, p := range strings.Split(s, ";") {
+		if _, err := strconv.Atoi(p); err != nil {
+			return "", fmt.Errorf("invalid color %q", s)
+		}
+	}
+	return s, nil
+}
+
+// set changes one setting of the theme, as written in a theme file
+func (th *theme) set(key, value string) error {
+	var err error
+	switch key {
+	case "running":
+		th.running, err = parseColor(value)
+	case "overtime":
+		th.overtime, err = parseColor(value)
+	case "done":
+		th.done, err = parseColor(value)
+	case "bar":
+		chars := []rune(value)
+		if len(chars) != 2 {
+			return fmt.Errorf("bar needs two characters, filled and empty, not %q", value)
+		}
+		th.barFull, th.barEmpty = string(chars[0]), string(chars[1])
+	case "gradient":
+		th.gradient, err = strconv.ParseBool(value)
+	case "style":
+		if _, ok := styles[value]; !ok && value != "plain" {
+			return fmt.Errorf("unknown style %q", value)
+		}
+		th.style = value
+	case "size":
+		th.size, err = strconv.Atoi(value)
+		if err == nil && th.size < 1 {
+			err = fmt.Errorf("size must be at least 1")
+		}
+	default:
+		return fmt.Errorf("unknown setting %q", key)
+	}
+	return err
+}
+
+// themesDir is where -theme looks for theme files
+func themesDir() string {
+	return filepath.Join(configDir(), "themes")
+}
+
+// loadTheme reads a theme file over the default theme. A name with a path
+// separator is a file, anything else is name.theme in the themes directory.
+// Theme files have one "key = value" setting per line:
+//
+//	# amber on black, like an old terminal
+//	running = yellow
+//	overtime = bright red
+//	done = bold green
+//	bar = #-
+//	gradient = true
+//	style = seven
+//	size = 2
+func loadTheme(name string) (theme, error) {
+	th := currentTheme
+	path := name
+	if !strings.ContainsRune(name, filepath.Separator) {
+		path = filepath.Join(themesDir(), name+".theme")
+	}
+	err := readSettings(path, th.set)
+	return th, err
+}
+
+// stateColor is the theme color for a timer at duration of total. A
+// stopwatch past its -limit is shown as overtime.
+func stateColor(mode Mode, total time.Duration, duration time.Duration) string {
+	switch {
+	case mode == STOPWATCH && flags.limit > 0 && duration > flags.limit:
+		return currentTheme.overtime
+	case duration > total:
+		return currentTheme.overtime
+	case duration == total:
+		return currentTheme.done
+	}
+	return currentTheme.running
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"net"
+	"strings"
+)
+
+// udpMax is the longest command a datagram can carry
+const udpMax = 512
+
+// listenUDP runs the commands sent to addr in single datagrams, like
+// "pause" or "add 5m", on t until ctx is cancelled. Nothing is sent back:
+// foot pedals and stream decks fire and forget.
+func listenUDP(ctx context.Context, addr string, t *timer) error {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+	go func() {
+		b := make([]byte, udpMax)
+		for {
+			n, _, err := conn.ReadFrom(b)
+			if err != nil {
+				return
+			}
+			for _, line := range strings.Split(string(b[:n]), "\n") {
+				if strings.TrimSpace(line) == "" {
+					continue
+				}
+				select {
+				case t.commands <- line:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return nil
+}
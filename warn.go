@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// warning is a -warn threshold: how much time is left on a countdown when
+// it goes off, and how it tells the user
+type warning struct {
+	left    time.Duration
+	channel string // bell, notify, speak or run:command
+}
+
+// warnings are the -warn flags, one per threshold
+type warnings []warning
+
+func (w *warnings) Set(s string) error {
+	parts := strings.SplitN(s, "=", 2)
+	d, err := parseDuration(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return err
+	}
+	if d < 0 {
+		return fmt.Errorf("negative warning %v", d)
+	}
+	ch := "bell"
+	if len(parts) == 2 {
+		ch = strings.TrimSpace(parts[1])
+	}
+	switch {
+	case ch == "bell", ch == "notify", ch == "speak":
+	case strings.HasPrefix(ch, "run:") && len(ch) > len("run:"):
+	default:
+		return fmt.Errorf("unknown warning %q, not bell, notify, speak or run:command", ch)
+	}
+	*w = append(*w, warning{left: d, channel: ch})
+	return nil
+}
+
+func (w *warnings) String() string {
+	if w == nil {
+		return ""
+	}
+	var s []string
+	for _, x := range *w {
+		s = append(s, fmt.Sprintf("%v=%s", x.left, x.channel))
+	}
+	return strings.Join(s, ",")
+}
+
+// checkWarnings sets off the warnings passed since the last tick, going by
+// the time left then and now, so that adding time to a countdown lets them
+// go off again on the way down
+func (t *timer) checkWarnings() {
+	left := t.duration - t.elapsed
+	for _, w := range flags.warnings {
+		if t.lastLeft > w.left && left <= w.left {
+			t.warn(w)
+		}
+	}
+	t.lastLeft = left
+}
+
+func (t *timer) warn(w warning) {
+	t.rec.event("warn", w.left.String())
+	msg := spokenDuration(w.left) + " left"
+	if w.left == 0 {
+		msg = "Time is up"
+	}
+	if flags.label != "" {
+		msg = flags.label + ": " + strings.ToLower(msg[:1]) + msg[1:]
+	}
+	switch {
+	case w.channel == "bell":
+		screen.bell()
+	case w.channel == "notify":
+		start(notifyCommand("gutimer", msg))
+	case w.channel == "speak":
+		start(speakCommand(msg))
+	default:
+		runHook(strings.TrimPrefix(w.channel, "run:"), "warn", flags.label)
+	}
+}
+
+// spokenDuration writes d the way it would be said: 5 minutes, 1 minute 30
+// seconds
+func spokenDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	parts := []struct {
+		n    time.Duration
+		unit string
+	}{{d / time.Hour, "hour"}, {d % time.Hour / time.Minute, "minute"}, {d % time.Minute / time.Second, "second"}}
+	var words []string
+	for _, p := range parts {
+		switch {
+		case p.n == 1:
+			words = append(words, "1 "+p.unit)
+		case p.n > 1:
+			words = append(words, fmt.Sprintf("%d %ss", p.n, p.unit))
+		}
+	}
+	if len(words) == 0 {
+		return "0 seconds"
+	}
+	return strings.Join(words, " ")
+}
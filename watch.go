@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"syscall"
+	"time"
+)
+
+// how often -watch-pid checks on the process
+const watchInterval = 100 * time.Millisecond
+
+// processAlive reports whether pid exists. A process owned by someone else
+// can't be signalled but is still there.
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
+
+// watchPid sends 0 on e when pid exits, which stops the timer
+func watchPid(ctx context.Context, pid int, e chan<- int) {
+	tk := time.NewTicker(watchInterval)
+	defer tk.Stop()
+	for {
+		select {
+		case <-tk.C:
+			if !processAlive(pid) {
+				send(ctx, e, 0)
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}